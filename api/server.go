@@ -0,0 +1,199 @@
+// Package api предоставляет HTTP/JSON обёртку над graph.Graph, чтобы
+// обнаруженные циклы можно было запрашивать по требованию, а не только
+// читать из stdout CLI.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NimuraF/diplom-accumulate-service/internal/graph"
+	"github.com/NimuraF/diplom-accumulate-service/internal/metrics"
+	"github.com/NimuraF/diplom-accumulate-service/internal/store"
+)
+
+// Server оборачивает graph.Graph HTTP-эндпоинтами. graph.Graph сам по себе
+// потокобезопасен (мьютекс внутри), так что ingestion (POST /rates, фиды) и
+// запросы (GET /cycles, /graph) могут идти одновременно.
+type Server struct {
+	g          *graph.Graph
+	store      *store.Store // может быть nil, если запущено без --db
+	httpServer *http.Server
+}
+
+// NewServer создаёт Server поверх графа g, который будет слушать addr после
+// вызова ListenAndServe. st, если не nil, используется для сохранения
+// найденных GET /cycles циклов в историю.
+func NewServer(g *graph.Graph, addr string, st *store.Store) *Server {
+	s := &Server{g: g, store: st}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/rates", s.handleRates)
+	mux.HandleFunc("/cycles", s.handleCycles)
+	mux.HandleFunc("/graph", s.handleGraph)
+
+	s.httpServer = &http.Server{Addr: addr, Handler: mux}
+	return s
+}
+
+// ListenAndServe блокируется, пока сервер не будет остановлен через Shutdown
+// или не упадёт с ошибкой.
+func (s *Server) ListenAndServe() error {
+	err := s.httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown грациозно останавливает сервер, дожидаясь завершения уже
+// принятых запросов в пределах ctx.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// rateRequest — одна запись, принимаемая POST /rates. Levels, если указан,
+// задаёт полную глубину стакана (топ-N уровней от лучшей цены к худшей), как
+// и Levels в RateRecord файлового формата (main.go); Rate остаётся ценой
+// верхнего уровня для вызовов, которые глубину не отдают.
+type rateRequest struct {
+	From     string             `json:"from"`
+	To       string             `json:"to"`
+	Rate     float64            `json:"rate"`
+	Fee      float64            `json:"fee"`
+	Exchange string             `json:"exchange"`
+	Levels   []graph.PriceLevel `json:"levels,omitempty"`
+}
+
+// handleRates добавляет одну или несколько котировок в граф. Тело запроса —
+// JSON-массив rateRequest, как и у существующего формата файла с курсами.
+func (s *Server) handleRates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var records []rateRequest
+	if err := json.NewDecoder(r.Body).Decode(&records); err != nil {
+		http.Error(w, "decode: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	added := 0
+	for _, rec := range records {
+		if len(rec.Levels) > 0 {
+			s.g.AddEdgeDepth(rec.From, rec.To, rec.Levels, rec.Fee, rec.Exchange)
+			added++
+			continue
+		}
+		if rec.Rate <= 0 {
+			continue
+		}
+		s.g.AddEdge(rec.From, rec.To, rec.Rate, rec.Fee, rec.Exchange)
+		added++
+	}
+	vertices, edges := s.g.Dump()
+	metrics.ObserveGraphSnapshot(vertices, edges)
+	writeJSON(w, http.StatusAccepted, map[string]int{"added": added})
+}
+
+// cycleTypeAliases переводит короткие значения query-параметра type
+// ("inter"/"intra") в CycleType, который хранит graph.CycleInfo.
+var cycleTypeAliases = map[string]string{
+	"inter": "inter-exchange",
+	"intra": "intra-exchange",
+}
+
+// defaultNotional — notional (в единицах валюты start), применяемый, если
+// query-параметр notional не задан. Совпадает со значением по умолчанию
+// флага --notional у CLI.
+const defaultNotional = 1000
+
+// handleCycles ищет циклы и ранжирует их по фактически исполнимому профиту
+// (RankByExecutedProfit/SimulateCycle), как это делает CLI через scanAndPrint,
+// а не по теоретическому top-of-book профиту:
+// GET /cycles?start=USDT&max_depth=5&min_profit=0.001&type=inter&mode=bf&notional=1000
+func (s *Server) handleCycles(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	opts := graph.CycleOptions{Mode: "bf"}
+	if v := q.Get("mode"); v != "" {
+		opts.Mode = v
+	}
+	if start := q.Get("start"); start != "" {
+		opts.Starts = []string{start}
+	}
+	if v := q.Get("max_depth"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "bad max_depth: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts.MaxDepth = n
+	}
+	if v := q.Get("min_profit"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			http.Error(w, "bad min_profit: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts.MinProfit = f
+	}
+	if v := q.Get("type"); v != "" {
+		if alias, ok := cycleTypeAliases[v]; ok {
+			opts.CycleType = alias
+		} else {
+			opts.CycleType = v
+		}
+	}
+
+	notional := float64(defaultNotional)
+	if v := q.Get("notional"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			http.Error(w, "bad notional: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		notional = f
+	}
+
+	scanStart := time.Now()
+	cycles := s.g.FindCycles(opts)
+	ranked := s.g.RankByExecutedProfit(cycles, notional)
+	duration := time.Since(scanStart)
+
+	metrics.ObserveScan(cycles, duration)
+	if s.store != nil {
+		s.store.RecordRanked(context.Background(), ranked, notional, duration)
+	}
+
+	writeJSON(w, http.StatusOK, ranked)
+}
+
+// graphDump — JSON-представление графа для GET /graph.
+type graphDump struct {
+	Vertices []string         `json:"vertices"`
+	Edges    []graph.EdgeView `json:"edges"`
+}
+
+func (s *Server) handleGraph(w http.ResponseWriter, r *http.Request) {
+	vertices, edges := s.g.Dump()
+	metrics.ObserveGraphSnapshot(vertices, edges)
+	writeJSON(w, http.StatusOK, graphDump{Vertices: vertices, Edges: edges})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NimuraF/diplom-accumulate-service/internal/graph"
+)
+
+// TestHandleRatesThenCyclesRoundTrip posts a profitable loop through
+// POST /rates and checks GET /cycles reports it, exercising the same
+// ingestion+query path concurrent callers rely on (see the Server doc
+// comment on thread-safety).
+func TestHandleRatesThenCyclesRoundTrip(t *testing.T) {
+	s := NewServer(graph.NewGraph(), "", nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rates":
+			s.handleRates(w, r)
+		case "/cycles":
+			s.handleCycles(w, r)
+		case "/graph":
+			s.handleGraph(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	body, _ := json.Marshal([]rateRequest{
+		{From: "USDT", To: "BTC", Rate: 0.00002, Exchange: "ex1"},
+		{From: "BTC", To: "USDT", Rate: 60000, Exchange: "ex1"},
+	})
+	resp, err := http.Post(srv.URL+"/rates", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST /rates: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST /rates status = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+	var added map[string]int
+	if err := json.NewDecoder(resp.Body).Decode(&added); err != nil {
+		t.Fatalf("decode /rates response: %v", err)
+	}
+	if added["added"] != 2 {
+		t.Fatalf("POST /rates added = %d, want 2", added["added"])
+	}
+
+	resp, err = http.Get(srv.URL + "/cycles?start=USDT&mode=bf")
+	if err != nil {
+		t.Fatalf("GET /cycles: %v", err)
+	}
+	defer resp.Body.Close()
+	var ranked []graph.RankedCycle
+	if err := json.NewDecoder(resp.Body).Decode(&ranked); err != nil {
+		t.Fatalf("decode /cycles response: %v", err)
+	}
+	if len(ranked) != 1 {
+		t.Fatalf("GET /cycles returned %d cycles, want 1: %+v", len(ranked), ranked)
+	}
+	if ranked[0].SimulatedProfit <= 0 {
+		t.Errorf("cycle simulated_profit = %v, want > 0", ranked[0].SimulatedProfit)
+	}
+
+	resp, err = http.Get(srv.URL + "/graph")
+	if err != nil {
+		t.Fatalf("GET /graph: %v", err)
+	}
+	defer resp.Body.Close()
+	var dump graphDump
+	if err := json.NewDecoder(resp.Body).Decode(&dump); err != nil {
+		t.Fatalf("decode /graph response: %v", err)
+	}
+	if len(dump.Vertices) != 2 {
+		t.Errorf("GET /graph vertices = %v, want 2", dump.Vertices)
+	}
+	if len(dump.Edges) != 2 {
+		t.Errorf("GET /graph edges = %v, want 2", dump.Edges)
+	}
+}
+
+// TestHandleCyclesRejectsBadMaxDepth checks query-parameter validation
+// returns 400 instead of silently ignoring an unparseable max_depth.
+func TestHandleCyclesRejectsBadMaxDepth(t *testing.T) {
+	s := NewServer(graph.NewGraph(), "", nil)
+	req := httptest.NewRequest(http.MethodGet, "/cycles?max_depth=nope", nil)
+	w := httptest.NewRecorder()
+	s.handleCycles(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+// TestHandleRatesRejectsWrongMethod checks POST /rates rejects non-POST
+// requests rather than decoding an empty GET body.
+func TestHandleRatesRejectsWrongMethod(t *testing.T) {
+	s := NewServer(graph.NewGraph(), "", nil)
+	req := httptest.NewRequest(http.MethodGet, "/rates", nil)
+	w := httptest.NewRecorder()
+	s.handleRates(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,38 @@
+package feed
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff — экспоненциальная задержка с джиттером для переподключений
+// WS-клиентов. Не потокобезопасен — используется одним ридер-лупом на источник.
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+
+	attempt int
+}
+
+// NewBackoff создаёт Backoff с базовой задержкой base и потолком max.
+func NewBackoff(base, max time.Duration) *Backoff {
+	return &Backoff{Base: base, Max: max}
+}
+
+// Next возвращает задержку перед очередной попыткой переподключения и
+// увеличивает счётчик попыток.
+func (b *Backoff) Next() time.Duration {
+	d := b.Base << uint(b.attempt)
+	if d <= 0 || d > b.Max {
+		d = b.Max
+	}
+	b.attempt++
+	// Полный джиттер: случайное значение в [d/2, d).
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Reset сбрасывает счётчик попыток после успешного подключения.
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
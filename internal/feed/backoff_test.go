@@ -0,0 +1,33 @@
+package feed
+
+import "testing"
+
+// TestBackoffNextStaysWithinBounds checks that Next never returns a delay
+// below Base/2 (the bottom of the full-jitter range for the first attempt)
+// or above Max, across enough attempts to run past the point where Base<<n
+// overflows into the Max clamp.
+func TestBackoffNextStaysWithinBounds(t *testing.T) {
+	b := NewBackoff(10, 100)
+	for i := 0; i < 20; i++ {
+		d := b.Next()
+		if d < 5 {
+			t.Fatalf("attempt %d: Next() = %v, want >= 5 (half of Base)", i, d)
+		}
+		if d > 100 {
+			t.Fatalf("attempt %d: Next() = %v, want <= Max (100)", i, d)
+		}
+	}
+}
+
+// TestBackoffResetRestartsFromBase checks that Reset makes the next delay
+// small again instead of continuing to grow from the exhausted attempt count.
+func TestBackoffResetRestartsFromBase(t *testing.T) {
+	b := NewBackoff(10, 1000)
+	for i := 0; i < 10; i++ {
+		b.Next()
+	}
+	b.Reset()
+	if d := b.Next(); d > 10 {
+		t.Errorf("Next() after Reset = %v, want <= Base (10)", d)
+	}
+}
@@ -0,0 +1,142 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/NimuraF/diplom-accumulate-service/internal/graph"
+)
+
+// BinanceSource подключается к публичному комбинированному WS-стриму
+// bookTicker Binance и отдаёт лучшую цену бид/аск по каждому символу.
+type BinanceSource struct {
+	// Pairs сопоставляет символ биржи (в нижнем регистре, напр. "btcusdt")
+	// валютной паре в терминах Graph.
+	Pairs map[string]Pair
+	// BaseURL переопределяется в тестах; по умолчанию публичный эндпоинт Binance.
+	BaseURL string
+}
+
+// NewBinanceSource создаёт источник для перечисленных пар.
+func NewBinanceSource(pairs map[string]Pair) *BinanceSource {
+	return &BinanceSource{Pairs: pairs, BaseURL: "wss://stream.binance.com:9443"}
+}
+
+func (s *BinanceSource) Name() string { return "binance" }
+
+type binanceBookTickerEnvelope struct {
+	Data binanceBookTicker `json:"data"`
+}
+
+type binanceBookTicker struct {
+	Symbol string `json:"s"`
+	BidPx  string `json:"b"`
+	BidQty string `json:"B"`
+	AskPx  string `json:"a"`
+	AskQty string `json:"A"`
+}
+
+func (s *BinanceSource) streamURL() string {
+	streams := make([]string, 0, len(s.Pairs))
+	for sym := range s.Pairs {
+		streams = append(streams, strings.ToLower(sym)+"@bookTicker")
+	}
+	return fmt.Sprintf("%s/stream?streams=%s", s.BaseURL, strings.Join(streams, "/"))
+}
+
+// Stream реализует RateSource. Переподключается с экспоненциальным backoff
+// при обрыве соединения, пока ctx не будет отменён.
+func (s *BinanceSource) Stream(ctx context.Context) (<-chan RateRecord, error) {
+	out := make(chan RateRecord)
+	go func() {
+		defer close(out)
+		bo := NewBackoff(time.Second, 30*time.Second)
+		for ctx.Err() == nil {
+			conn, _, err := websocket.DefaultDialer.DialContext(ctx, s.streamURL(), nil)
+			if err != nil {
+				time.Sleep(bo.Next())
+				continue
+			}
+			bo.Reset()
+			s.readLoop(ctx, conn, out)
+			conn.Close()
+			if ctx.Err() == nil {
+				time.Sleep(bo.Next())
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (s *BinanceSource) readLoop(ctx context.Context, conn *websocket.Conn, out chan<- RateRecord) {
+	for {
+		var env binanceBookTickerEnvelope
+		if err := conn.ReadJSON(&env); err != nil {
+			return
+		}
+		pair, ok := s.Pairs[strings.ToLower(env.Data.Symbol)]
+		if !ok {
+			continue
+		}
+		rate, err := parseFloat(env.Data.AskPx)
+		if err != nil || rate <= 0 {
+			continue
+		}
+		rec := RateRecord{
+			From:     pair.Quote,
+			To:       pair.Base,
+			Rate:     1 / rate,
+			Exchange: s.Name(),
+			Ts:       time.Now(),
+			Levels:   askLevel(rate, env.Data.AskQty),
+		}
+		select {
+		case out <- rec:
+		case <-ctx.Done():
+			return
+		}
+
+		bidRate, err := parseFloat(env.Data.BidPx)
+		if err != nil || bidRate <= 0 {
+			continue
+		}
+		select {
+		case out <- RateRecord{
+			From:     pair.Base,
+			To:       pair.Quote,
+			Rate:     bidRate,
+			Exchange: s.Name(),
+			Ts:       time.Now(),
+			Levels:   bidLevel(bidRate, env.Data.BidQty),
+		}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// askLevel строит одноуровневый стакан для ребра Quote->Base: askPx — цена
+// лучшего аска в котировках Quote за Base, askQty — доступный объём в Base
+// (как его отдаёт bookTicker), который нужно перевести в Quote, т.к.
+// PriceLevel.Size хранится в единицах From соответствующего ребра.
+func askLevel(askPx float64, askQty string) []graph.PriceLevel {
+	qty, err := parseFloat(askQty)
+	if err != nil || qty <= 0 {
+		return nil
+	}
+	return []graph.PriceLevel{{Price: 1 / askPx, Size: qty * askPx}}
+}
+
+// bidLevel строит одноуровневый стакан для ребра Base->Quote: bidPx и bidQty
+// уже в нужных единицах (цена в Quote за Base, объём в Base = From этого ребра).
+func bidLevel(bidPx float64, bidQty string) []graph.PriceLevel {
+	qty, err := parseFloat(bidQty)
+	if err != nil || qty <= 0 {
+		return nil
+	}
+	return []graph.PriceLevel{{Price: bidPx, Size: qty}}
+}
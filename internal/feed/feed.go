@@ -0,0 +1,51 @@
+// Package feed подключается к публичным WS-стримам бирж и отдаёт котировки
+// в унифицированном виде, чтобы Graph мог обновляться инкрементально вместо
+// перечитывания файла с курсами каждые несколько секунд.
+package feed
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/NimuraF/diplom-accumulate-service/internal/graph"
+)
+
+// RateRecord — одна котировка, полученная от источника. Levels, если
+// заполнен, задаёт глубину стакана на момент котировки (graph.PriceLevel, как
+// и в файловом формате main.go), чтобы Graph.SimulateCycle мог учитывать
+// проскальзывание и для потоковых источников, а не только для статичного
+// файла с курсами. Публичные ticker-стримы Binance/Kraken отдают только
+// top-of-book, поэтому BinanceSource/KrakenSource заполняют Levels одним
+// уровнем по реальному объёму верхней котировки, а не инфинитным размером.
+type RateRecord struct {
+	From     string
+	To       string
+	Rate     float64
+	Exchange string
+	Ts       time.Time
+	Levels   []graph.PriceLevel
+}
+
+// Pair описывает валютную пару в терминах Graph (From -> To) для одного
+// тикера биржи, например {Base: "BTC", Quote: "USDT"} для символа BTCUSDT.
+type Pair struct {
+	Base  string
+	Quote string
+}
+
+// RateSource отдаёт поток котировок с биржи. Канал закрывается, когда ctx
+// отменяется или соединение окончательно потеряно (после исчерпания попыток
+// переподключения вызывающая сторона решает, пересоздавать ли источник).
+type RateSource interface {
+	// Name возвращает имя источника; совпадает с полем Exchange в RateRecord.
+	Name() string
+	// Stream устанавливает соединение и возвращает канал котировок.
+	Stream(ctx context.Context) (<-chan RateRecord, error)
+}
+
+// parseFloat — обёртка над strconv.ParseFloat для разбора цен бирж, которые
+// приходят строками в JSON.
+func parseFloat(s string) (float64, error) {
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,178 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/NimuraF/diplom-accumulate-service/internal/graph"
+)
+
+// KrakenSource подключается к публичному WS-стриму ticker Kraken (v1,
+// каналы вида ["XBT/USD", ...]) и отдаёт лучшую цену бид/аск по каждой паре.
+type KrakenSource struct {
+	// Pairs сопоставляет символ пары Kraken (напр. "XBT/USD") паре в
+	// терминах Graph.
+	Pairs   map[string]Pair
+	BaseURL string
+}
+
+// NewKrakenSource создаёт источник для перечисленных пар.
+func NewKrakenSource(pairs map[string]Pair) *KrakenSource {
+	return &KrakenSource{Pairs: pairs, BaseURL: "wss://ws.kraken.com"}
+}
+
+func (s *KrakenSource) Name() string { return "kraken" }
+
+// krakenTickerPayload соответствует позиционному формату Kraken:
+// [channelID, {"a": [ask,...], "b": [bid,...]}, "ticker", "XBT/USD"].
+type krakenTickerPayload struct {
+	Data []json.RawMessage
+}
+
+func (p *krakenTickerPayload) UnmarshalJSON(b []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	p.Data = raw
+	return nil
+}
+
+func (s *KrakenSource) subscribeMsg() ([]byte, error) {
+	symbols := make([]string, 0, len(s.Pairs))
+	for sym := range s.Pairs {
+		symbols = append(symbols, sym)
+	}
+	return json.Marshal(map[string]interface{}{
+		"event": "subscribe",
+		"pair":  symbols,
+		"subscription": map[string]string{
+			"name": "ticker",
+		},
+	})
+}
+
+// Stream реализует RateSource, переподключаясь с экспоненциальным backoff.
+func (s *KrakenSource) Stream(ctx context.Context) (<-chan RateRecord, error) {
+	out := make(chan RateRecord)
+	go func() {
+		defer close(out)
+		bo := NewBackoff(time.Second, 30*time.Second)
+		for ctx.Err() == nil {
+			conn, _, err := websocket.DefaultDialer.DialContext(ctx, s.BaseURL, nil)
+			if err != nil {
+				time.Sleep(bo.Next())
+				continue
+			}
+			sub, err := s.subscribeMsg()
+			if err != nil || conn.WriteMessage(websocket.TextMessage, sub) != nil {
+				conn.Close()
+				time.Sleep(bo.Next())
+				continue
+			}
+			bo.Reset()
+			s.readLoop(ctx, conn, out)
+			conn.Close()
+			if ctx.Err() == nil {
+				time.Sleep(bo.Next())
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (s *KrakenSource) readLoop(ctx context.Context, conn *websocket.Conn, out chan<- RateRecord) {
+	for {
+		_, raw, err := conn.ReadMessage()
+		if err != nil {
+			// Соединение разорвано (EOF/close и т.п.) — возвращаемся в Stream,
+			// чтобы переподключиться, а не крутить цикл на мёртвом conn.
+			return
+		}
+		var payload krakenTickerPayload
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			// Не каждое сообщение Kraken — тикер (бывают heartbeat/event
+			// объекты, которые не парсятся как массив); пропускаем их, не
+			// разрывая соединение.
+			continue
+		}
+		if len(payload.Data) < 4 {
+			continue
+		}
+		var symbol string
+		if err := json.Unmarshal(payload.Data[3], &symbol); err != nil {
+			continue
+		}
+		pair, ok := s.Pairs[symbol]
+		if !ok {
+			continue
+		}
+		var book struct {
+			Ask []string `json:"a"`
+			Bid []string `json:"b"`
+		}
+		if err := json.Unmarshal(payload.Data[1], &book); err != nil || len(book.Ask) == 0 || len(book.Bid) == 0 {
+			continue
+		}
+		ask, err := parseFloat(book.Ask[0])
+		if err == nil && ask > 0 {
+			select {
+			case out <- RateRecord{
+				From:     pair.Quote,
+				To:       pair.Base,
+				Rate:     1 / ask,
+				Exchange: s.Name(),
+				Ts:       time.Now(),
+				Levels:   krakenAskLevel(ask, book.Ask),
+			}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		bid, err := parseFloat(book.Bid[0])
+		if err == nil && bid > 0 {
+			select {
+			case out <- RateRecord{
+				From:     pair.Base,
+				To:       pair.Quote,
+				Rate:     bid,
+				Exchange: s.Name(),
+				Ts:       time.Now(),
+				Levels:   krakenBidLevel(bid, book.Bid),
+			}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// krakenAskLevel строит одноуровневый стакан для ребра Quote->Base из ask —
+// [price, wholeLotVolume, lotVolume]: lotVolume (ask[2]) в единицах Base,
+// переводим в Quote, т.к. PriceLevel.Size хранится в единицах From этого ребра.
+func krakenAskLevel(askPx float64, ask []string) []graph.PriceLevel {
+	if len(ask) < 3 {
+		return nil
+	}
+	qty, err := parseFloat(ask[2])
+	if err != nil || qty <= 0 {
+		return nil
+	}
+	return []graph.PriceLevel{{Price: 1 / askPx, Size: qty * askPx}}
+}
+
+// krakenBidLevel строит одноуровневый стакан для ребра Base->Quote из bid:
+// lotVolume (bid[2]) уже в единицах Base = From этого ребра.
+func krakenBidLevel(bidPx float64, bid []string) []graph.PriceLevel {
+	if len(bid) < 3 {
+		return nil
+	}
+	qty, err := parseFloat(bid[2])
+	if err != nil || qty <= 0 {
+		return nil
+	}
+	return []graph.PriceLevel{{Price: bidPx, Size: qty}}
+}
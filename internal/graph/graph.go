@@ -0,0 +1,882 @@
+// Package graph содержит детектор валютного арбитража: граф обменных курсов
+// и алгоритмы поиска прибыльных циклов (DFS-перебор и Bellman-Ford). Пакет
+// не печатает результаты и не знает про CLI/HTTP — он используется и CLI-
+// инструментом main, и HTTP API (package api), которые оборачивают его
+// каждый по-своему.
+package graph
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const INF = math.MaxFloat64
+
+// PriceLevel — один уровень стакана: по этой цене (уже за вычетом комиссии)
+// доступен указанный объём в единицах валюты From соответствующего Edge.
+type PriceLevel struct {
+	Price float64 `json:"price"`
+	Size  float64 `json:"size"`
+}
+
+// Edge описывает одно направление с весом и биржей. Book хранит глубину
+// стакана (топ-N уровней, от лучшей цены к худшей); Weight всегда считается
+// от цены лучшего уровня, так что поиск циклов (FindCycles) не меняется, а
+// SimulateCycle может дополнительно учитывать проскальзывание по объёму.
+type Edge struct {
+	To       string  `json:"to"`
+	Weight   float64 // -log(Book[0].Price)
+	Exchange string
+	Ts       time.Time    // время последнего обновления котировки
+	Book     []PriceLevel // глубина стакана, net of fee, лучшая цена первой
+}
+
+// Graph хранит вершины (currencies), ребра и для каждого from->to список
+// возможных Edge. Безопасен для конкурентного использования: ingestion
+// (AddEdge/UpdateEdge/ExpireOlderThan) и запросы (FindCycles/Dump) могут
+// выполняться из разных горутин одновременно.
+type Graph struct {
+	mu         sync.RWMutex
+	vertices   []string
+	vertexSeen map[string]bool // дедуп для vertices; edges не годится, т.к. у него нет ключа для валюты, встречавшейся только как To
+	edges      map[string][]Edge
+}
+
+func NewGraph() *Graph {
+	return &Graph{
+		vertices:   []string{},
+		vertexSeen: make(map[string]bool),
+		edges:      make(map[string][]Edge),
+	}
+}
+
+// registerVertex добавляет name в vertices, если он ещё не встречался.
+// Вызывается только под g.mu.
+func (g *Graph) registerVertex(name string) {
+	if g.vertexSeen[name] {
+		return
+	}
+	g.vertexSeen[name] = true
+	g.vertices = append(g.vertices, name)
+}
+
+// AddEdge регистрирует новую вершину и добавляет ребро по единственной цене
+// верхнего уровня книги, без ограничения на объём (Size: +Inf) — для
+// источников, которые не отдают глубину стакана (файл с курсами, тикеры
+// top-of-book). fee — комиссия тейкера на этой бирже (доля, например 0.001
+// для 0.1%).
+func (g *Graph) AddEdge(from, to string, rate, fee float64, exchange string) {
+	g.AddEdgeDepth(from, to, []PriceLevel{{Price: rate, Size: math.Inf(1)}}, fee, exchange)
+}
+
+// AddEdgeDepth — как AddEdge, но принимает полный стакан (несколько уровней
+// глубины, от лучшей цены к худшей) вместо единственной цены верхнего
+// уровня. Комиссия применяется к цене каждого уровня, так что Edge.Book
+// хранит уже чистые, net-of-fee цены — их использует SimulateCycle.
+func (g *Graph) AddEdgeDepth(from, to string, book []PriceLevel, fee float64, exchange string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.registerVertex(from)
+	g.registerVertex(to)
+
+	netBook := make([]PriceLevel, len(book))
+	for i, lvl := range book {
+		netBook[i] = PriceLevel{Price: lvl.Price * (1 - fee), Size: lvl.Size}
+	}
+	g.edges[from] = append(g.edges[from], Edge{
+		To:       to,
+		Weight:   -math.Log(topPrice(netBook)),
+		Exchange: exchange,
+		Ts:       time.Now(),
+		Book:     netBook,
+	})
+}
+
+// UpdateEdge заменяет котировку для тройки (from, to, exchange) единственной
+// ценой верхнего уровня (Size: +Inf), либо добавляет новое ребро, если такой
+// тройки ещё не было. Используется потоковым приёмом котировок
+// (internal/feed), который обычно отдаёт только top-of-book. fee — комиссия
+// тейкера на exchange (доля), как и в AddEdge.
+func (g *Graph) UpdateEdge(from, to string, rate, fee float64, exchange string, ts time.Time) {
+	g.UpdateEdgeDepth(from, to, []PriceLevel{{Price: rate, Size: math.Inf(1)}}, fee, exchange, ts)
+}
+
+// UpdateEdgeDepth — как UpdateEdge, но принимает полный стакан. Комиссия
+// применяется к цене каждого уровня, как и в AddEdgeDepth, так что Edge.Book
+// остаётся net-of-fee независимо от того, каким методом ребро попало в граф.
+// Работает за O(deg(from)), поскольку ищет совпадение только среди исходящих
+// рёбер from.
+func (g *Graph) UpdateEdgeDepth(from, to string, book []PriceLevel, fee float64, exchange string, ts time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.registerVertex(from)
+	g.registerVertex(to)
+
+	netBook := make([]PriceLevel, len(book))
+	for i, lvl := range book {
+		netBook[i] = PriceLevel{Price: lvl.Price * (1 - fee), Size: lvl.Size}
+	}
+	weight := -math.Log(topPrice(netBook))
+	for i, e := range g.edges[from] {
+		if e.To == to && e.Exchange == exchange {
+			g.edges[from][i].Weight = weight
+			g.edges[from][i].Ts = ts
+			g.edges[from][i].Book = netBook
+			return
+		}
+	}
+	g.edges[from] = append(g.edges[from], Edge{To: to, Weight: weight, Exchange: exchange, Ts: ts, Book: netBook})
+}
+
+// topPrice возвращает цену лучшего уровня книги, либо 0, если книга пуста.
+func topPrice(book []PriceLevel) float64 {
+	if len(book) == 0 {
+		return 0
+	}
+	return book[0].Price
+}
+
+// ExpireOlderThan удаляет рёбра, чья последняя котировка старше d, чтобы
+// просроченные цены не порождали фантомный арбитраж в event-driven детекторе.
+func (g *Graph) ExpireOlderThan(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	cutoff := time.Now().Add(-d)
+	for from, edges := range g.edges {
+		fresh := edges[:0]
+		for _, e := range edges {
+			if e.Ts.After(cutoff) {
+				fresh = append(fresh, e)
+			}
+		}
+		if len(fresh) == 0 {
+			delete(g.edges, from)
+			continue
+		}
+		g.edges[from] = fresh
+	}
+}
+
+// EdgeView — экспортируемый, выровненный под JSON снимок одного ребра,
+// отдаваемый через GET /graph.
+type EdgeView struct {
+	From     string    `json:"from"`
+	To       string    `json:"to"`
+	Exchange string    `json:"exchange"`
+	Weight   float64   `json:"weight"`
+	Ts       time.Time `json:"ts"`
+}
+
+// Dump возвращает снимок вершин и рёбер графа — используется GET /graph и
+// отладкой, не участвует в детекции циклов.
+func (g *Graph) Dump() ([]string, []EdgeView) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	vertices := append([]string(nil), g.vertices...)
+	var edges []EdgeView
+	for from, es := range g.edges {
+		for _, e := range es {
+			edges = append(edges, EdgeView{From: from, To: e.To, Exchange: e.Exchange, Weight: e.Weight, Ts: e.Ts})
+		}
+	}
+	return vertices, edges
+}
+
+// CycleInfo хранит один найденный цикл и его характеристики.
+type CycleInfo struct {
+	Path []string `json:"path"`
+	// Exchanges — отсортированный список уникальных бирж, задействованных
+	// в цикле; по нему определяется CycleType ("inter-exchange" при >1).
+	Exchanges []string `json:"exchanges"`
+	CycleType string   `json:"cycle_type"`
+	Profit    float64  `json:"profit"`
+}
+
+// CycleOptions настраивает поиск в FindCycles.
+type CycleOptions struct {
+	// Mode выбирает алгоритм: "bf" (Bellman-Ford, по умолчанию) или "dfs"
+	// (Johnson — исчерпывающий перебор всех элементарных циклов).
+	Mode string
+	// Starts — вершины, которых должен касаться цикл; пусто => все вершины
+	// графа. В режиме dfs ограничивает возвращаемые циклы теми, что проходят
+	// хотя бы через одну из них, и разворачивает Path так, чтобы она начиналась
+	// (и заканчивалась) именно на этой вершине.
+	Starts []string
+	// MaxDepth ограничивает длину цикла (число вершин) в режиме dfs; 0 =>
+	// значение по умолчанию (5).
+	MaxDepth int
+	// MinProfit отбрасывает циклы с профитом ниже этого значения (доля, не %).
+	MinProfit float64
+	// CycleType, если не пусто, оставляет только циклы этого типа
+	// ("inter-exchange" или "intra-exchange").
+	CycleType string
+}
+
+// FindCycles ищет прибыльные циклы согласно opts и возвращает их
+// отсортированными по убыванию профита. Обрезку количества результатов
+// (например, топ-10 для CLI) делает вызывающий код.
+func (g *Graph) FindCycles(opts CycleOptions) []CycleInfo {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	starts := opts.Starts
+	if len(starts) == 0 {
+		starts = g.vertices
+	}
+	maxDepth := opts.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = 5
+	}
+
+	var cycles []CycleInfo
+	var cyclesMtx sync.Mutex
+	uniqueCycles := make(map[string]bool)
+
+	if opts.Mode == "dfs" {
+		found := g.johnsonCycles(maxDepth)
+		restrictStarts := len(opts.Starts) > 0
+		startSet := make(map[string]bool, len(opts.Starts))
+		for _, s := range opts.Starts {
+			startSet[s] = true
+		}
+		for _, c := range found {
+			if restrictStarts {
+				rotated, ok := rotateToStart(c.Path, startSet)
+				if !ok {
+					continue
+				}
+				c.Path = rotated
+			}
+			norm := normalizeCycle(c.Path)
+			if uniqueCycles[norm] {
+				continue
+			}
+			uniqueCycles[norm] = true
+			cycles = append(cycles, c)
+		}
+	} else {
+		var wg sync.WaitGroup
+		for _, start := range starts {
+			wg.Add(1)
+			go func(s string) {
+				defer wg.Done()
+				found := g.detectArbitrageBF(s)
+				cyclesMtx.Lock()
+				for _, c := range found {
+					norm := normalizeCycle(c.Path)
+					if uniqueCycles[norm] {
+						continue
+					}
+					uniqueCycles[norm] = true
+					cycles = append(cycles, c)
+				}
+				cyclesMtx.Unlock()
+			}(start)
+		}
+		wg.Wait()
+	}
+
+	filtered := cycles[:0]
+	for _, c := range cycles {
+		if c.Profit < opts.MinProfit {
+			continue
+		}
+		if opts.CycleType != "" && c.CycleType != opts.CycleType {
+			continue
+		}
+		filtered = append(filtered, c)
+	}
+	cycles = filtered
+
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i].Profit > cycles[j].Profit
+	})
+	return cycles
+}
+
+// Leg — одна исполняемая нога цикла: сколько и по какой цене конвертировать
+// на конкретной бирже.
+type Leg struct {
+	Exchange string `json:"exchange"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	// Side показывает направление конвертации в терминах графа (From->To);
+	// само направление и есть сторона сделки, отдельного понятия buy/sell
+	// граф не хранит.
+	Side  string  `json:"side"`
+	Price float64 `json:"price"`
+	// Size — объём в единицах From, который удалось исполнить на этом шаге.
+	Size float64 `json:"size"`
+}
+
+// SimulateCycle проходит закрытый цикл path (path[0] == path[len-1], как его
+// возвращает FindCycles) и на каждом шаге потребляет уровни стакана
+// соответствующего ребра, начиная с notional единиц path[0]. Возвращает
+// реализованный профит (доля), оценку исполнимого объёма filled и список
+// конкретных ног для исполнения.
+//
+// filled — приближение: минимальная по всем шагам доля заявленного на этом
+// шаге объёма, которую реально покрыла глубина стакана, применённая к
+// notional. Точный расчёт потребовал бы обратного прохода с учётом того, что
+// нехватка глубины на позднем шаге должна уменьшать объём на предыдущих —
+// здесь это не моделируется, что приемлемо для ранжирования кандидатов.
+func (g *Graph) SimulateCycle(path []string, notional float64) (profit, filled float64, legs []Leg) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if len(path) < 2 || notional <= 0 {
+		return 0, 0, nil
+	}
+
+	amount := notional
+	minFillRatio := 1.0
+	for i := 0; i < len(path)-1; i++ {
+		from, to := path[i], path[i+1]
+		edge, ok := g.bestEdge(from, to)
+		if !ok {
+			return 0, 0, legs
+		}
+		book := edge.Book
+		if len(book) == 0 {
+			book = []PriceLevel{{Price: math.Exp(-edge.Weight), Size: math.Inf(1)}}
+		}
+		consumed, out := walkBook(book, amount)
+		if amount > 0 {
+			if ratio := consumed / amount; ratio < minFillRatio {
+				minFillRatio = ratio
+			}
+		}
+		price := 0.0
+		if consumed > 0 {
+			price = out / consumed
+		}
+		legs = append(legs, Leg{
+			Exchange: edge.Exchange,
+			From:     from,
+			To:       to,
+			Side:     from + "->" + to,
+			Price:    price,
+			Size:     consumed,
+		})
+		amount = out
+	}
+
+	filled = notional * minFillRatio
+	profit = (amount - notional) / notional
+	return profit, filled, legs
+}
+
+// bestEdge возвращает ребро с наилучшей (наименьшим весом, т.е. наибольшим
+// эффективным курсом) котировкой среди всех рёбер from->to, независимо от
+// биржи. Вызывается только под g.mu (см. SimulateCycle), поэтому сам лок не берёт.
+func (g *Graph) bestEdge(from, to string) (Edge, bool) {
+	var best Edge
+	found := false
+	for _, e := range g.edges[from] {
+		if e.To != to {
+			continue
+		}
+		if !found || e.Weight < best.Weight {
+			best = e
+			found = true
+		}
+	}
+	return best, found
+}
+
+// walkBook потребляет уровни стакана book (от лучшего к худшему) на объём
+// amount единиц From и возвращает, сколько фактически удалось конвертировать
+// (consumed <= amount, меньше при нехватке глубины) и сколько получилось To.
+func walkBook(book []PriceLevel, amount float64) (consumed, out float64) {
+	remaining := amount
+	for _, lvl := range book {
+		if remaining <= 0 {
+			break
+		}
+		take := lvl.Size
+		if take > remaining {
+			take = remaining
+		}
+		out += take * lvl.Price
+		remaining -= take
+		consumed += take
+	}
+	return consumed, out
+}
+
+// RankedCycle — цикл, дополненный результатом симуляции исполнения по
+// реальной глубине стакана на заданный notional.
+type RankedCycle struct {
+	CycleInfo
+	SimulatedProfit float64 `json:"simulated_profit"`
+	Filled          float64 `json:"filled"`
+	Legs            []Leg   `json:"legs"`
+}
+
+// RankByExecutedProfit пересчитывает и пересортировывает cycles по
+// фактически исполнимому профиту на notional единиц валюты path[0] — то есть
+// по результату SimulateCycle, а не по теоретическому произведению
+// top-of-book курсов, которое хранит CycleInfo.Profit.
+func (g *Graph) RankByExecutedProfit(cycles []CycleInfo, notional float64) []RankedCycle {
+	ranked := make([]RankedCycle, 0, len(cycles))
+	for _, c := range cycles {
+		profit, filled, legs := g.SimulateCycle(c.Path, notional)
+		ranked = append(ranked, RankedCycle{CycleInfo: c, SimulatedProfit: profit, Filled: filled, Legs: legs})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		return ranked[i].SimulatedProfit > ranked[j].SimulatedProfit
+	})
+	return ranked
+}
+
+// johnsonCycles перечисляет все элементарные циклы графа длиной (числом
+// вершин) не более maxLen с помощью алгоритма Джонсона: вычисляем SCC
+// оставшегося графа (tarjanSCCs), ищем SCC, содержащую наименьшую из ещё не
+// обработанных вершин s, запускаем от s поиск с blocked/B (circuit), затем
+// удаляем s из графа и повторяем, пока вершины не кончатся. В отличие от
+// прежнего depth-limited DFS, каждый элементарный цикл находится и
+// сообщается ровно один раз, а не по разу на каждую стартовую вершину.
+// Возвращаются только прибыльные циклы (profit > 0).
+func (g *Graph) johnsonCycles(maxLen int) []CycleInfo {
+	adj := make(map[string][]Edge, len(g.edges))
+	for from, es := range g.edges {
+		adj[from] = append([]Edge(nil), es...)
+	}
+	remaining := append([]string(nil), g.vertices...)
+	sort.Strings(remaining)
+
+	var cycles []CycleInfo
+	// seen деддуплицирует по нормализованной последовательности вершин, но
+	// хранит индекс в cycles, а не просто факт "уже видели": между одной и
+	// той же парой вершин может быть несколько рёбер (разные биржи), и для
+	// уже увиденной последовательности нужно оставить лучшую по Profit
+	// комбинацию бирж, а не первую найденную.
+	seen := make(map[string]int)
+
+	for len(remaining) > 0 {
+		sccs := tarjanSCCs(remaining, adj)
+		s := remaining[0]
+
+		var scc []string
+		for _, c := range sccs {
+			for _, v := range c {
+				if v == s {
+					scc = c
+					break
+				}
+			}
+			if scc != nil {
+				break
+			}
+		}
+
+		// scc всегда содержит хотя бы s; даже при len(scc)==1 в нём может
+		// быть прибыльный self-loop s->s, так что circuit запускаем всегда,
+		// а не только для SCC из нескольких вершин.
+		sccSet := make(map[string]bool, len(scc))
+		for _, v := range scc {
+			sccSet[v] = true
+		}
+		blocked := make(map[string]bool, len(scc))
+		unblockMap := make(map[string]map[string]bool, len(scc))
+		g.circuit(s, s, sccSet, adj, blocked, unblockMap, []string{s}, nil, 0, maxLen, &cycles, seen)
+
+		// Убираем s из графа: будущие SCC и поиски его уже не видят.
+		remaining = remaining[1:]
+		delete(adj, s)
+		for from, es := range adj {
+			fresh := es[:0]
+			for _, e := range es {
+				if e.To != s {
+					fresh = append(fresh, e)
+				}
+			}
+			adj[from] = fresh
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i].Profit > cycles[j].Profit
+	})
+	return cycles
+}
+
+// circuit — модифицированный DFS алгоритма Джонсона: ищет пути из v обратно
+// в s в пределах одной SCC (sccSet), не заходя дважды в одну и ту же
+// вершину (blocked), и для каждого найденного замыкания длиной не более
+// maxLen регистрирует прибыльный цикл в cycles (дедуп по normalizeCycle
+// через seen, который хранит индекс в cycles лучшей уже найденной
+// комбинации — см. комментарий у seen в johnsonCycles). Возвращает true,
+// если из v был найден хоть один путь в s (не обязательно прибыльный) — от
+// этого зависит, кого разблокировать (unblock) и кого вместо этого
+// добавить в B, чтобы он разблокировался только когда найдётся путь через
+// одного из его соседей.
+func (g *Graph) circuit(
+	s, v string,
+	sccSet map[string]bool,
+	adj map[string][]Edge,
+	blocked map[string]bool,
+	B map[string]map[string]bool,
+	path, exchanges []string,
+	accWeight float64,
+	maxLen int,
+	cycles *[]CycleInfo,
+	seen map[string]int,
+) bool {
+	found := false
+	blocked[v] = true
+
+	for _, e := range adj[v] {
+		w := e.To
+		if !sccSet[w] {
+			continue
+		}
+		if w == s {
+			found = true
+			weight := accWeight + e.Weight
+			if len(path) > maxLen || weight >= 0 {
+				continue
+			}
+			fullPath := append(append([]string(nil), path...), s)
+			norm := normalizeCycle(fullPath)
+
+			exList := exchangeList(append(append([]string(nil), exchanges...), e.Exchange))
+			ctype := "intra-exchange"
+			if len(exList) > 1 {
+				ctype = "inter-exchange"
+			}
+			profit := math.Exp(-weight) - 1
+
+			// Та же последовательность вершин может замыкаться через разные
+			// пары рёбер, если пара (from,to) котируется на нескольких
+			// биржах — оставляем комбинацию с наибольшим Profit, а не
+			// первую найденную.
+			if idx, ok := seen[norm]; ok {
+				if profit > (*cycles)[idx].Profit {
+					(*cycles)[idx] = CycleInfo{
+						Path:      fullPath,
+						Exchanges: exList,
+						CycleType: ctype,
+						Profit:    profit,
+					}
+				}
+				continue
+			}
+			seen[norm] = len(*cycles)
+			*cycles = append(*cycles, CycleInfo{
+				Path:      fullPath,
+				Exchanges: exList,
+				CycleType: ctype,
+				Profit:    profit,
+			})
+		} else if !blocked[w] && len(path) < maxLen {
+			if g.circuit(s, w, sccSet, adj, blocked, B, append(path, w), append(exchanges, e.Exchange), accWeight+e.Weight, maxLen, cycles, seen) {
+				found = true
+			}
+		}
+	}
+
+	if found {
+		unblock(v, blocked, B)
+	} else {
+		for _, e := range adj[v] {
+			w := e.To
+			if !sccSet[w] {
+				continue
+			}
+			if B[w] == nil {
+				B[w] = make(map[string]bool)
+			}
+			B[w][v] = true
+		}
+	}
+	return found
+}
+
+// unblock снимает блокировку с v и каскадно с каждой вершины в B[v], как
+// того требует алгоритм Джонсона, чтобы заблокированная ветка могла быть
+// переисследована, как только через неё обнаруживается путь к s.
+func unblock(v string, blocked map[string]bool, B map[string]map[string]bool) {
+	blocked[v] = false
+	for w := range B[v] {
+		delete(B[v], w)
+		if blocked[w] {
+			unblock(w, blocked, B)
+		}
+	}
+}
+
+// rotateToStart разворачивает замкнутый путь path (path[0] == path[len-1])
+// так, чтобы он начинался и заканчивался на первой по ходу пути вершине,
+// входящей в startSet. ok=false, если ни одна вершина path в startSet не входит.
+func rotateToStart(path []string, startSet map[string]bool) (rotated []string, ok bool) {
+	body := path[:len(path)-1]
+	for i, v := range body {
+		if startSet[v] {
+			r := append(append([]string(nil), body[i:]...), body[:i]...)
+			r = append(r, v)
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// tarjanSCCs возвращает компоненты сильной связности подграфа, заданного
+// вершинами vertices и рёбрами adj (рёбра за пределы vertices игнорируются),
+// алгоритмом Тарьяна.
+func tarjanSCCs(vertices []string, adj map[string][]Edge) [][]string {
+	inGraph := make(map[string]bool, len(vertices))
+	for _, v := range vertices {
+		inGraph[v] = true
+	}
+
+	index := 0
+	indices := make(map[string]int, len(vertices))
+	lowlink := make(map[string]int, len(vertices))
+	onStack := make(map[string]bool, len(vertices))
+	var stack []string
+	var sccs [][]string
+
+	var strongconnect func(v string)
+	strongconnect = func(v string) {
+		indices[v] = index
+		lowlink[v] = index
+		index++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, e := range adj[v] {
+			w := e.To
+			if !inGraph[w] {
+				continue
+			}
+			if _, visited := indices[w]; !visited {
+				strongconnect(w)
+				if lowlink[w] < lowlink[v] {
+					lowlink[v] = lowlink[w]
+				}
+			} else if onStack[w] && indices[w] < lowlink[v] {
+				lowlink[v] = indices[w]
+			}
+		}
+
+		if lowlink[v] == indices[v] {
+			var scc []string
+			for {
+				n := len(stack) - 1
+				w := stack[n]
+				stack = stack[:n]
+				onStack[w] = false
+				scc = append(scc, w)
+				if w == v {
+					break
+				}
+			}
+			sccs = append(sccs, scc)
+		}
+	}
+
+	for _, v := range vertices {
+		if _, visited := indices[v]; !visited {
+			strongconnect(v)
+		}
+	}
+	return sccs
+}
+
+// bfEdge — плоское представление ребра графа для прогона Bellman-Ford.
+type bfEdge struct {
+	From, To string
+	Weight   float64
+	Exchange string
+}
+
+// flatEdges возвращает все ребра графа одним срезом.
+func (g *Graph) flatEdges() []bfEdge {
+	var out []bfEdge
+	for from, es := range g.edges {
+		for _, e := range es {
+			out = append(out, bfEdge{From: from, To: e.To, Weight: e.Weight, Exchange: e.Exchange})
+		}
+	}
+	return out
+}
+
+// detectArbitrageBF ищет отрицательные циклы, достижимые из source, с помощью
+// Bellman-Ford: |V|-1 релаксаций по всем ребрам, затем ещё один, |V|-й проход,
+// на котором любое всё ещё релаксируемое ребро указывает на вершину внутри
+// (или недалеко от) отрицательного цикла. Чтобы гарантированно попасть в сам
+// цикл, а не на "хвост" пути к нему, откатываемся по predecessor[] ещё V раз,
+// после чего идём по predecessor до первого повторения вершины. Вызывается
+// только под g.mu (см. FindCycles), поэтому сам лок не берёт.
+func (g *Graph) detectArbitrageBF(source string) []CycleInfo {
+	dist := make(map[string]float64, len(g.vertices))
+	pred := make(map[string]string, len(g.vertices))
+	predEx := make(map[string]string, len(g.vertices))
+	predWeight := make(map[string]float64, len(g.vertices))
+	for _, v := range g.vertices {
+		dist[v] = INF
+	}
+	dist[source] = 0
+
+	edges := g.flatEdges()
+	n := len(g.vertices)
+
+	for i := 0; i < n-1; i++ {
+		changed := false
+		for _, e := range edges {
+			if dist[e.From] == INF {
+				continue
+			}
+			if nd := dist[e.From] + e.Weight; nd < dist[e.To] {
+				dist[e.To] = nd
+				pred[e.To] = e.From
+				predEx[e.To] = e.Exchange
+				predWeight[e.To] = e.Weight
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	var cycles []CycleInfo
+	seen := make(map[string]bool)
+edgeLoop:
+	for _, e := range edges {
+		if dist[e.From] == INF {
+			continue
+		}
+		if dist[e.From]+e.Weight >= dist[e.To] {
+			continue
+		}
+		// e.To лежит на пути, затронутом отрицательным циклом. Откатываемся
+		// V раз, чтобы гарантированно оказаться внутри цикла.
+		v := e.To
+		for i := 0; i < n; i++ {
+			if p, ok := pred[v]; ok {
+				v = p
+			}
+		}
+		// Теперь идём по predecessor, пока вершина не повторится — это и есть цикл.
+		// exchanges[k]/weights[k] — эксчейндж и вес ребра path[k+1]->path[k],
+		// то самое ребро, через которое Bellman-Ford релаксировал cur, а не
+		// произвольное ребро между теми же вершинами (их может быть
+		// несколько — разные биржи котируют одну и ту же пару).
+		path := []string{v}
+		exchanges := []string{}
+		weights := []float64{}
+		visited := map[string]int{v: 0}
+		cur := v
+		for {
+			p, ok := pred[cur]
+			if !ok {
+				// Откат по predecessor дошёл до вершины без предка (например,
+				// сам source) раньше, чем путь замкнулся — перелёт "хвоста".
+				// Это не повод терять циклы, уже найденные для других рёбер.
+				continue edgeLoop
+			}
+			path = append(path, p)
+			exchanges = append(exchanges, predEx[cur])
+			weights = append(weights, predWeight[cur])
+			if idx, ok := visited[p]; ok {
+				path = path[idx : len(path)-1]
+				exchanges = exchanges[idx:]
+				weights = weights[idx:]
+				reverse(path)
+				break
+			}
+			visited[p] = len(path) - 1
+			cur = p
+		}
+
+		norm := normalizeCycle(append(append([]string(nil), path...), path[0]))
+		if seen[norm] {
+			continue
+		}
+		seen[norm] = true
+
+		accWeight := 0.0
+		for _, w := range weights {
+			accWeight += w
+		}
+		if accWeight >= 0 {
+			continue
+		}
+
+		exList := exchangeList(exchanges)
+		ctype := "intra-exchange"
+		if len(exList) > 1 {
+			ctype = "inter-exchange"
+		}
+
+		cycles = append(cycles, CycleInfo{
+			Path:      append(append([]string(nil), path...), path[0]),
+			Exchanges: exList,
+			CycleType: ctype,
+			Profit:    math.Exp(-accWeight) - 1,
+		})
+	}
+	return cycles
+}
+
+// exchangeList возвращает отсортированный список уникальных бирж из exchanges.
+func exchangeList(exchanges []string) []string {
+	seen := make(map[string]struct{}, len(exchanges))
+	var out []string
+	for _, ex := range exchanges {
+		if _, ok := seen[ex]; ok {
+			continue
+		}
+		seen[ex] = struct{}{}
+		out = append(out, ex)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// reverse переворачивает срез строк на месте.
+func reverse(s []string) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+// normalizeCycle возвращает каноническое представление цикла без повторяющейся исходной валюты.
+func normalizeCycle(cycle []string) string {
+	n := len(cycle)
+	if n == 0 {
+		return ""
+	}
+	// Исключаем повторную исходную валюту (последний элемент), т.к. он совпадает с первым.
+	cycleNoDup := cycle[:n-1]
+	best := make([]string, len(cycleNoDup))
+	copy(best, cycleNoDup)
+	for i := 1; i < len(cycleNoDup); i++ {
+		rotated := append(append([]string(nil), cycleNoDup[i:]...), cycleNoDup[:i]...)
+		if lexLess(rotated, best) {
+			best = rotated
+		}
+	}
+	return strings.Join(best, "->")
+}
+
+// lexLess обеспечивает лексикографическое сравнение двух срезов строк.
+func lexLess(a, b []string) bool {
+	n := len(a)
+	for i := 0; i < n; i++ {
+		if a[i] < b[i] {
+			return true
+		} else if a[i] > b[i] {
+			return false
+		}
+	}
+	return false
+}
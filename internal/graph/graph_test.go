@@ -0,0 +1,298 @@
+package graph
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+// TestDumpDeduplicatesVertices checks that a currency which first appears
+// only as the To side of an edge (the common case: every A->B edge from a
+// feed/file arrives alongside a B->A edge) isn't counted twice just because
+// it later appears as a From — vertex membership must not be derived from
+// g.edges, which only gets a key for a currency once it's been a From.
+func TestDumpDeduplicatesVertices(t *testing.T) {
+	g := NewGraph()
+	g.AddEdge("USDT", "BTC", 0.00002, 0, "ex1")
+	g.AddEdge("BTC", "ETH", 20.0, 0, "ex1")
+	g.AddEdge("ETH", "USDT", 2600.0, 0, "ex1")
+
+	vertices, _ := g.Dump()
+	if len(vertices) != 3 {
+		t.Fatalf("got %d vertices, want 3: %v", len(vertices), vertices)
+	}
+	sorted := append([]string(nil), vertices...)
+	sort.Strings(sorted)
+	want := []string{"BTC", "ETH", "USDT"}
+	for i, v := range want {
+		if sorted[i] != v {
+			t.Errorf("vertices = %v, want %v", sorted, want)
+			break
+		}
+	}
+}
+
+// TestFindCyclesDFSEnumeratesElementaryCycles builds a small graph with two
+// overlapping 2-cycles sharing vertex B:
+//
+//	A <-> B <-> C
+//
+// A->B (rate 2) and B->A (rate 0.6) multiply to 1.2 (20% profit); B->C and
+// C->B are the same, independently profitable. By hand, the only elementary
+// cycles are A-B-A and B-C-B: a cycle through all three vertices (e.g.
+// A->B->C->B->A) would revisit B and so isn't elementary, and Johnson's
+// algorithm must not report it.
+func TestFindCyclesDFSEnumeratesElementaryCycles(t *testing.T) {
+	g := NewGraph()
+	g.AddEdge("A", "B", 2.0, 0, "ex1")
+	g.AddEdge("B", "A", 0.6, 0, "ex1")
+	g.AddEdge("B", "C", 2.0, 0, "ex1")
+	g.AddEdge("C", "B", 0.6, 0, "ex1")
+
+	cycles := g.FindCycles(CycleOptions{Mode: "dfs"})
+	if len(cycles) != 2 {
+		t.Fatalf("got %d cycles, want 2: %+v", len(cycles), cycles)
+	}
+
+	seenPairs := make(map[string]bool)
+	for _, c := range cycles {
+		if len(c.Path) != 3 || c.Path[0] != c.Path[2] {
+			t.Fatalf("cycle %v is not a closed 2-vertex cycle", c.Path)
+		}
+		seenPairs[c.Path[0]+c.Path[1]] = true
+		if diff := c.Profit - 0.2; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("cycle %v: profit = %v, want ~0.2", c.Path, c.Profit)
+		}
+	}
+	if !seenPairs["AB"] && !seenPairs["BA"] {
+		t.Errorf("expected an A-B cycle among %+v", cycles)
+	}
+	if !seenPairs["BC"] && !seenPairs["CB"] {
+		t.Errorf("expected a B-C cycle among %+v", cycles)
+	}
+}
+
+// TestFindCyclesDFSRespectsMaxDepth checks that MaxDepth is a cap on cycle
+// length (vertex count): with MaxDepth=1 neither 2-vertex cycle fits, so
+// nothing should be reported.
+func TestFindCyclesDFSRespectsMaxDepth(t *testing.T) {
+	g := NewGraph()
+	g.AddEdge("A", "B", 2.0, 0, "ex1")
+	g.AddEdge("B", "A", 0.6, 0, "ex1")
+
+	cycles := g.FindCycles(CycleOptions{Mode: "dfs", MaxDepth: 1})
+	if len(cycles) != 0 {
+		t.Fatalf("got %d cycles with MaxDepth=1, want 0: %+v", len(cycles), cycles)
+	}
+}
+
+// TestFindCyclesDFSSkipsUnprofitable checks that a cycle whose rates
+// multiply to less than 1 (a loss) is not reported.
+func TestFindCyclesDFSSkipsUnprofitable(t *testing.T) {
+	g := NewGraph()
+	g.AddEdge("A", "B", 2.0, 0, "ex1")
+	g.AddEdge("B", "A", 0.4, 0, "ex1") // product 0.8: a loss, not a cycle to report
+
+	cycles := g.FindCycles(CycleOptions{Mode: "dfs"})
+	if len(cycles) != 0 {
+		t.Fatalf("got %d cycles for an unprofitable loop, want 0: %+v", len(cycles), cycles)
+	}
+}
+
+// TestFindCyclesDFSFindsSelfLoop checks that a profitable self-loop edge
+// (from == to, e.g. a data glitch or misconfigured feed) is still reported
+// as a 1-vertex elementary cycle, since a single vertex with a self-loop is
+// its own SCC and must not be skipped by johnsonCycles.
+func TestFindCyclesDFSFindsSelfLoop(t *testing.T) {
+	g := NewGraph()
+	g.AddEdge("USD", "USD", 1.5, 0, "ex1")
+
+	cycles := g.FindCycles(CycleOptions{Mode: "dfs"})
+	if len(cycles) != 1 {
+		t.Fatalf("got %d cycles, want 1: %+v", len(cycles), cycles)
+	}
+	if got := cycles[0].Path; len(got) != 2 || got[0] != "USD" || got[1] != "USD" {
+		t.Errorf("cycle path = %v, want [USD USD]", got)
+	}
+	if diff := cycles[0].Profit - 0.5; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("profit = %v, want 0.5", cycles[0].Profit)
+	}
+}
+
+// TestFindCyclesBFUsesRelaxedEdge checks that Bellman-Ford mode reports the
+// profit and exchange of the edge it actually relaxed through, not just any
+// edge between the same pair of vertices. Two exchanges quote A->B here
+// ("bad" at 1.01, "good" at 1.50); only "good" closes a profitable cycle
+// with B->A at 1.0, so the reported cycle must come from "good" regardless
+// of which AddEdge call happened to run first.
+func TestFindCyclesBFUsesRelaxedEdge(t *testing.T) {
+	g := NewGraph()
+	g.AddEdge("A", "B", 1.01, 0, "bad")
+	g.AddEdge("A", "B", 1.50, 0, "good")
+	g.AddEdge("B", "A", 1.0, 0, "ex1")
+
+	cycles := g.FindCycles(CycleOptions{})
+	if len(cycles) != 1 {
+		t.Fatalf("got %d cycles, want 1: %+v", len(cycles), cycles)
+	}
+	c := cycles[0]
+	if diff := c.Profit - 0.5; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("profit = %v, want 0.5 (the \"good\" edge)", c.Profit)
+	}
+	found := false
+	for _, ex := range c.Exchanges {
+		if ex == "good" {
+			found = true
+		}
+		if ex == "bad" {
+			t.Errorf("exchanges = %v, \"bad\" must not appear: it was never relaxed", c.Exchanges)
+		}
+	}
+	if !found {
+		t.Errorf("exchanges = %v, want \"good\" among them", c.Exchanges)
+	}
+}
+
+// TestFindCyclesDFSKeepsBestParallelEdge mirrors
+// TestFindCyclesBFUsesRelaxedEdge for dfs mode: circuit's dedup is keyed by
+// the normalized vertex sequence alone, so it must keep the best-profit
+// combination of parallel edges for that sequence rather than whichever one
+// adj[v]'s insertion order happens to reach first.
+func TestFindCyclesDFSKeepsBestParallelEdge(t *testing.T) {
+	g := NewGraph()
+	g.AddEdge("A", "B", 1.01, 0, "bad")
+	g.AddEdge("A", "B", 1.50, 0, "good")
+	g.AddEdge("B", "A", 1.0, 0, "ex1")
+
+	cycles := g.FindCycles(CycleOptions{Mode: "dfs"})
+	if len(cycles) != 1 {
+		t.Fatalf("got %d cycles, want 1: %+v", len(cycles), cycles)
+	}
+	c := cycles[0]
+	if diff := c.Profit - 0.5; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("profit = %v, want 0.5 (the \"good\" edge)", c.Profit)
+	}
+	found := false
+	for _, ex := range c.Exchanges {
+		if ex == "good" {
+			found = true
+		}
+		if ex == "bad" {
+			t.Errorf("exchanges = %v, \"bad\" must not appear: \"good\" dominates it", c.Exchanges)
+		}
+	}
+	if !found {
+		t.Errorf("exchanges = %v, want \"good\" among them", c.Exchanges)
+	}
+}
+
+// TestSimulateCycleExhaustsBookDepth checks that when the requested notional
+// exceeds the total size available across all levels of the book, walkBook
+// (via SimulateCycle) stops at the book's total size instead of pretending
+// the rest filled at the last level's price: filled must reflect the true
+// shortfall, not notional.
+func TestSimulateCycleExhaustsBookDepth(t *testing.T) {
+	g := NewGraph()
+	g.AddEdgeDepth("A", "B", []PriceLevel{{Price: 2.0, Size: 10}, {Price: 1.5, Size: 10}}, 0, "ex1")
+	g.AddEdge("B", "A", 1.0, 0, "ex1")
+
+	_, filled, legs := g.SimulateCycle([]string{"A", "B", "A"}, 100)
+
+	// Book only covers 20 units of A; the A->B leg can't consume more than
+	// that, so minFillRatio = 20/100 and filled = notional * that ratio.
+	if diff := filled - 20; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("filled = %v, want 20 (book depth caps A->B at 20)", filled)
+	}
+	if len(legs) != 2 {
+		t.Fatalf("got %d legs, want 2: %+v", len(legs), legs)
+	}
+	if diff := legs[0].Size - 20; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("A->B leg size = %v, want 20 (book exhausted)", legs[0].Size)
+	}
+}
+
+// TestSimulateCycleLegPriceReflectsConsumedVolume checks that a leg's Price
+// is the blended price actually realized across the levels it consumed, not
+// just the top-of-book price — i.e. consuming into a worse second level must
+// show up as a price between the two levels, not equal to either alone.
+func TestSimulateCycleLegPriceReflectsConsumedVolume(t *testing.T) {
+	g := NewGraph()
+	// 10 units at 2.0, then 10 more at 1.0: consuming 20 units should realize
+	// an average price of (10*2.0 + 10*1.0)/20 = 1.5, not the top level's 2.0.
+	g.AddEdgeDepth("A", "B", []PriceLevel{{Price: 2.0, Size: 10}, {Price: 1.0, Size: 10}}, 0, "ex1")
+	g.AddEdge("B", "A", 1.0, 0, "ex1")
+
+	_, _, legs := g.SimulateCycle([]string{"A", "B", "A"}, 20)
+
+	if len(legs) != 2 {
+		t.Fatalf("got %d legs, want 2: %+v", len(legs), legs)
+	}
+	if diff := legs[0].Price - 1.5; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("A->B leg price = %v, want 1.5 (blended across both levels)", legs[0].Price)
+	}
+	if diff := legs[0].Size - 20; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("A->B leg size = %v, want 20", legs[0].Size)
+	}
+}
+
+// TestFindCyclesDFSRestrictsToStart checks that Starts filters the
+// elementary cycles down to those touching one of the given vertices, and
+// rotates the reported path to start (and end) there.
+func TestFindCyclesDFSRestrictsToStart(t *testing.T) {
+	g := NewGraph()
+	g.AddEdge("A", "B", 2.0, 0, "ex1")
+	g.AddEdge("B", "A", 0.6, 0, "ex1")
+	g.AddEdge("B", "C", 2.0, 0, "ex1")
+	g.AddEdge("C", "B", 0.6, 0, "ex1")
+
+	cycles := g.FindCycles(CycleOptions{Mode: "dfs", Starts: []string{"C"}})
+	if len(cycles) != 1 {
+		t.Fatalf("got %d cycles restricted to C, want 1: %+v", len(cycles), cycles)
+	}
+	if cycles[0].Path[0] != "C" || cycles[0].Path[len(cycles[0].Path)-1] != "C" {
+		t.Errorf("cycle %v should start and end at C", cycles[0].Path)
+	}
+}
+
+// TestUpdateEdgeNetsFee guards against the streaming ingestion path
+// (internal/feed, wired through runFeed in main.go) quietly going back to
+// gross-of-fees profit: UpdateEdge/UpdateEdgeDepth must net fee into Book
+// and Weight exactly like AddEdge/AddEdgeDepth do, not just store the raw
+// quote.
+func TestUpdateEdgeNetsFee(t *testing.T) {
+	g := NewGraph()
+	g.UpdateEdge("A", "B", 2.0, 0.01, "ex1", time.Now())
+
+	want := NewGraph()
+	want.AddEdge("A", "B", 2.0, 0.01, "ex1")
+
+	got, ok := g.bestEdge("A", "B")
+	if !ok {
+		t.Fatal("UpdateEdge did not add an edge")
+	}
+	wantEdge, _ := want.bestEdge("A", "B")
+	if got.Weight != wantEdge.Weight {
+		t.Errorf("UpdateEdge weight = %v, want %v (AddEdge with the same fee)", got.Weight, wantEdge.Weight)
+	}
+	if got.Book[0].Price != wantEdge.Book[0].Price {
+		t.Errorf("UpdateEdge book price = %v, want %v (net of fee)", got.Book[0].Price, wantEdge.Book[0].Price)
+	}
+}
+
+// TestUpdateEdgeDepthNetsFeeOnReplace checks the in-place replace branch of
+// UpdateEdgeDepth (matching (from, to, exchange) already in the graph) also
+// nets fee into the replacement Book/Weight, not just the first-seen branch.
+func TestUpdateEdgeDepthNetsFeeOnReplace(t *testing.T) {
+	g := NewGraph()
+	g.UpdateEdgeDepth("A", "B", []PriceLevel{{Price: 2.0, Size: 1}}, 0, "ex1", time.Now())
+	g.UpdateEdgeDepth("A", "B", []PriceLevel{{Price: 3.0, Size: 1}}, 0.02, "ex1", time.Now())
+
+	edge, ok := g.bestEdge("A", "B")
+	if !ok {
+		t.Fatal("edge missing after replace")
+	}
+	want := 3.0 * (1 - 0.02)
+	if edge.Book[0].Price != want {
+		t.Errorf("replaced book price = %v, want %v (3.0 net of 2%% fee)", edge.Book[0].Price, want)
+	}
+}
@@ -0,0 +1,88 @@
+// Package metrics экспортирует Prometheus-коллекторы для детектора
+// арбитража, чтобы оператор мог построить график частоты возможностей,
+// распределения профита и состояния графа во времени, а не только читать
+// разовый вывод сканирования.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/NimuraF/diplom-accumulate-service/internal/graph"
+)
+
+var (
+	CyclesDetected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "arb_cycles_detected_total",
+		Help: "Total number of profitable cycles detected, by cycle type and exchange.",
+	}, []string{"type", "exchange"})
+
+	CycleProfitRatio = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "arb_cycle_profit_ratio",
+		Help:    "Distribution of detected cycle profit ratios (e.g. 0.01 = 1%).",
+		Buckets: []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.02, 0.05, 0.1},
+	})
+
+	DetectDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "arb_detect_duration_seconds",
+		Help:    "Wall-clock time taken by one cycle detection scan.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	GraphEdges = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "arb_graph_edges",
+		Help: "Number of edges currently held in the graph.",
+	})
+
+	GraphVertices = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "arb_graph_vertices",
+		Help: "Number of vertices (currencies) currently held in the graph.",
+	})
+
+	RateAgeSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "arb_rate_age_seconds",
+		Help:    "Age of quotes still present in the graph at scan time, to catch stale feeds.",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600},
+	})
+)
+
+// Serve блокируется, отдавая зарегистрированные коллекторы на /metrics по addr.
+func Serve(addr string) error {
+	return http.ListenAndServe(addr, promhttp.Handler())
+}
+
+// exchangeLabel схлопывает набор бирж цикла в одно значение лейбла низкой
+// кардинальности: саму биржу для intra-exchange циклов или "multi" для
+// inter-exchange, чтобы CyclesDetected не разрастался на отдельную серию
+// под каждую пару бирж.
+func exchangeLabel(exchanges []string) string {
+	if len(exchanges) == 1 {
+		return exchanges[0]
+	}
+	return "multi"
+}
+
+// ObserveScan фиксирует один завершённый скан детекции: счётчики по
+// каждому циклу и гистограмму профита, плюс wall-clock время скана.
+func ObserveScan(cycles []graph.CycleInfo, duration time.Duration) {
+	for _, c := range cycles {
+		CyclesDetected.WithLabelValues(c.CycleType, exchangeLabel(c.Exchanges)).Inc()
+		CycleProfitRatio.Observe(c.Profit)
+	}
+	DetectDuration.Observe(duration.Seconds())
+}
+
+// ObserveGraphSnapshot обновляет гейджи размера графа и гистограмму
+// возраста котировок по graph.Dump() текущего графа.
+func ObserveGraphSnapshot(vertices []string, edges []graph.EdgeView) {
+	GraphVertices.Set(float64(len(vertices)))
+	GraphEdges.Set(float64(len(edges)))
+	now := time.Now()
+	for _, e := range edges {
+		RateAgeSeconds.Observe(now.Sub(e.Ts).Seconds())
+	}
+}
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/NimuraF/diplom-accumulate-service/internal/graph"
+)
+
+// TestObserveGraphSnapshotCountsVerticesOnce guards against the gauge
+// silently going back to double-counting if graph.Dump ever regresses to
+// deduplicating vertices via the edges map again (see the chunk0-3 fix):
+// with bidirectional quotes (A->B and B->A, the common case from any
+// feed/file), Dump must report exactly 2 vertices, not 4.
+func TestObserveGraphSnapshotCountsVerticesOnce(t *testing.T) {
+	g := graph.NewGraph()
+	g.AddEdge("USDT", "BTC", 0.00002, 0, "ex1")
+	g.AddEdge("BTC", "USDT", 50000, 0, "ex1")
+
+	vertices, edges := g.Dump()
+	ObserveGraphSnapshot(vertices, edges)
+
+	if got := testutil.ToFloat64(GraphVertices); got != 2 {
+		t.Errorf("GraphVertices = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(GraphEdges); got != 2 {
+		t.Errorf("GraphEdges = %v, want 2", got)
+	}
+}
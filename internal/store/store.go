@@ -0,0 +1,197 @@
+// Package store сохраняет обнаруженные циклы арбитража в SQLite, чтобы их
+// можно было запросить потом (частота возможностей, распределение профита
+// во времени), а не только печатать или возвращать из одного живого скана.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/NimuraF/diplom-accumulate-service/internal/graph"
+)
+
+// migrations применяются по порядку, каждая один раз, отслеживаются через
+// schema_migrations. Дописать в конец этого слайса — единственный способ
+// изменить схему; уже выпущенные записи нельзя редактировать.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS cycles (
+		id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+		detected_at        TIMESTAMP NOT NULL,
+		path               TEXT NOT NULL,
+		exchanges          TEXT NOT NULL,
+		cycle_type         TEXT NOT NULL,
+		profit             REAL NOT NULL,
+		notional           REAL NOT NULL,
+		detect_duration_ms INTEGER NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_cycles_detected_at ON cycles(detected_at)`,
+}
+
+// CycleRecord — один обнаруженный цикл в том виде, в каком он хранится в
+// базе и возвращается из неё. Path и Exchanges туда и обратно сериализуются
+// как JSON-массивы в своих колонках.
+type CycleRecord struct {
+	ID             int64
+	Detected       time.Time
+	Path           []string
+	Exchanges      []string
+	CycleType      string
+	Profit         float64
+	Notional       float64
+	DetectDuration time.Duration
+}
+
+// Store оборачивает базу SQLite, хранящую историю циклов.
+type Store struct {
+	db *sql.DB
+}
+
+// Open открывает (создавая при необходимости) базу SQLite по path и
+// подтягивает её схему до актуальной через runMigrations.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("store: open %s: %w", path, err)
+	}
+	if err := runMigrations(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("store: migrate %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close освобождает дескриптор базы данных.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// runMigrations применяет все ещё не записанные в schema_migrations
+// миграции по порядку, каждую отдельным statement, так что частичный сбой
+// оставляет неприменённой только одну миграцию.
+func runMigrations(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return err
+	}
+	var applied int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied); err != nil {
+		return err
+	}
+	for i := applied; i < len(migrations); i++ {
+		if _, err := db.Exec(migrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i, err)
+		}
+		if _, err := db.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, i); err != nil {
+			return fmt.Errorf("migration %d: record version: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Record сохраняет один обнаруженный цикл.
+func (s *Store) Record(ctx context.Context, c CycleRecord) error {
+	path, err := json.Marshal(c.Path)
+	if err != nil {
+		return fmt.Errorf("store: marshal path: %w", err)
+	}
+	exchanges, err := json.Marshal(c.Exchanges)
+	if err != nil {
+		return fmt.Errorf("store: marshal exchanges: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO cycles (detected_at, path, exchanges, cycle_type, profit, notional, detect_duration_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		c.Detected, string(path), string(exchanges), c.CycleType, c.Profit, c.Notional, c.DetectDuration.Milliseconds())
+	if err != nil {
+		return fmt.Errorf("store: insert cycle: %w", err)
+	}
+	return nil
+}
+
+// RecordRanked сохраняет каждый ranked-цикл одного скана/запроса в s,
+// проставляя всем общие detected/notional/duration. Ошибки хранилища
+// логируются в stderr, а не возвращаются: сбой SQLite не должен прерывать
+// сам скан. Общая для CLI (main.go scanAndPrint) и HTTP API (api/server.go
+// handleCycles) логика — раньше дублировалась в обоих как recordCycles.
+func (s *Store) RecordRanked(ctx context.Context, ranked []graph.RankedCycle, notional float64, duration time.Duration) {
+	detected := time.Now()
+	for _, c := range ranked {
+		rec := CycleRecord{
+			Detected:       detected,
+			Path:           c.Path,
+			Exchanges:      c.Exchanges,
+			CycleType:      c.CycleType,
+			Profit:         c.SimulatedProfit,
+			Notional:       notional,
+			DetectDuration: duration,
+		}
+		if err := s.Record(ctx, rec); err != nil {
+			fmt.Fprintln(os.Stderr, "store:", err)
+		}
+	}
+}
+
+// Filter сужает результаты, возвращаемые Query. Нулевое значение поля
+// означает "без фильтра", кроме Limit, где 0 значит "без ограничения".
+// MinProfit — указатель, потому что профит может быть отрицательным
+// (SimulateCycle учитывает проскальзывание и комиссии), так что нулевое
+// float64 не может служить признаком "фильтр не задан".
+type Filter struct {
+	Since     time.Time
+	CycleType string
+	MinProfit *float64
+	Limit     int
+}
+
+// Query возвращает циклы, подходящие под f, начиная с самых недавних.
+func (s *Store) Query(ctx context.Context, f Filter) ([]CycleRecord, error) {
+	query := `SELECT id, detected_at, path, exchanges, cycle_type, profit, notional, detect_duration_ms FROM cycles WHERE 1 = 1`
+	var args []interface{}
+	if f.MinProfit != nil {
+		query += ` AND profit >= ?`
+		args = append(args, *f.MinProfit)
+	}
+	if !f.Since.IsZero() {
+		query += ` AND detected_at >= ?`
+		args = append(args, f.Since)
+	}
+	if f.CycleType != "" {
+		query += ` AND cycle_type = ?`
+		args = append(args, f.CycleType)
+	}
+	query += ` ORDER BY detected_at DESC`
+	if f.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, f.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("store: query: %w", err)
+	}
+	defer rows.Close()
+
+	var out []CycleRecord
+	for rows.Next() {
+		var c CycleRecord
+		var path, exchanges string
+		var durationMs int64
+		if err := rows.Scan(&c.ID, &c.Detected, &path, &exchanges, &c.CycleType, &c.Profit, &c.Notional, &durationMs); err != nil {
+			return nil, fmt.Errorf("store: scan: %w", err)
+		}
+		if err := json.Unmarshal([]byte(path), &c.Path); err != nil {
+			return nil, fmt.Errorf("store: unmarshal path: %w", err)
+		}
+		if err := json.Unmarshal([]byte(exchanges), &c.Exchanges); err != nil {
+			return nil, fmt.Errorf("store: unmarshal exchanges: %w", err)
+		}
+		c.DetectDuration = time.Duration(durationMs) * time.Millisecond
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
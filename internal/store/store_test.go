@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NimuraF/diplom-accumulate-service/internal/graph"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// TestOpenMigratesIdempotently checks that migrating a fresh database twice
+// (the path taken whenever the process restarts against an existing file)
+// doesn't re-run the CREATE TABLE/INDEX statements a second time.
+func TestOpenMigratesIdempotently(t *testing.T) {
+	s := openTestStore(t)
+	if err := runMigrations(s.db); err != nil {
+		t.Fatalf("second runMigrations: %v", err)
+	}
+	var applied int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied); err != nil {
+		t.Fatalf("count schema_migrations: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("schema_migrations has %d rows, want %d", applied, len(migrations))
+	}
+}
+
+// TestQueryMinProfitAllowsNegative guards against the Filter{} default
+// dropping losing trades: a nil MinProfit must return every record,
+// including one with a negative profit, and a set MinProfit must still
+// floor correctly. Regression test for the bug where Query unconditionally
+// added "WHERE profit >= ?" with the zero value of a float64 MinProfit.
+func TestQueryMinProfitAllowsNegative(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	records := []CycleRecord{
+		{Detected: time.Now(), Path: []string{"A", "B", "A"}, Exchanges: []string{"ex1"}, CycleType: "dfs", Profit: -0.01, Notional: 100},
+		{Detected: time.Now(), Path: []string{"A", "C", "A"}, Exchanges: []string{"ex1"}, CycleType: "dfs", Profit: 0.02, Notional: 100},
+	}
+	for _, r := range records {
+		if err := s.Record(ctx, r); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	got, err := s.Query(ctx, Filter{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Query(Filter{}) returned %d records, want 2 (no filter, including the loss): %+v", len(got), got)
+	}
+
+	floor := 0.0
+	got, err = s.Query(ctx, Filter{MinProfit: &floor})
+	if err != nil {
+		t.Fatalf("Query with MinProfit: %v", err)
+	}
+	if len(got) != 1 || got[0].Profit != 0.02 {
+		t.Fatalf("Query(MinProfit: 0) = %+v, want only the 0.02 record", got)
+	}
+}
+
+// TestQueryFiltersByCycleTypeAndSince checks the remaining Filter fields
+// compose with AND, and that Limit caps the most-recent-first results.
+func TestQueryFiltersByCycleTypeAndSince(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	old := time.Now().Add(-time.Hour)
+	recent := time.Now()
+	records := []CycleRecord{
+		{Detected: old, Path: []string{"A", "B", "A"}, Exchanges: []string{"ex1"}, CycleType: "dfs", Profit: 0.01, Notional: 100},
+		{Detected: recent, Path: []string{"A", "C", "A"}, Exchanges: []string{"ex1"}, CycleType: "bf", Profit: 0.01, Notional: 100},
+		{Detected: recent, Path: []string{"A", "D", "A"}, Exchanges: []string{"ex1"}, CycleType: "bf", Profit: 0.01, Notional: 100},
+	}
+	for _, r := range records {
+		if err := s.Record(ctx, r); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	got, err := s.Query(ctx, Filter{CycleType: "bf", Since: recent.Add(-time.Minute)})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Query(CycleType: bf, Since: recent) returned %d records, want 2: %+v", len(got), got)
+	}
+
+	got, err = s.Query(ctx, Filter{CycleType: "bf", Limit: 1})
+	if err != nil {
+		t.Fatalf("Query with Limit: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("Query(Limit: 1) returned %d records, want 1", len(got))
+	}
+}
+
+// TestRecordRankedPersistsEachCycle checks the shared CLI/HTTP persistence
+// helper writes one row per ranked cycle, carrying over the common
+// notional/duration and each cycle's own simulated profit — the logic that
+// used to be duplicated as recordCycles in both main.go and api/server.go.
+func TestRecordRankedPersistsEachCycle(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	ranked := []graph.RankedCycle{
+		{CycleInfo: graph.CycleInfo{Path: []string{"A", "B", "A"}, Exchanges: []string{"ex1"}, CycleType: "dfs"}, SimulatedProfit: 0.01},
+		{CycleInfo: graph.CycleInfo{Path: []string{"A", "C", "A"}, Exchanges: []string{"ex1"}, CycleType: "dfs"}, SimulatedProfit: -0.02},
+	}
+	s.RecordRanked(ctx, ranked, 500, 10*time.Millisecond)
+
+	got, err := s.Query(ctx, Filter{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("RecordRanked persisted %d rows, want 2: %+v", len(got), got)
+	}
+	for _, c := range got {
+		if c.Notional != 500 {
+			t.Errorf("record %+v has Notional %v, want 500", c, c.Notional)
+		}
+		if c.DetectDuration != 10*time.Millisecond {
+			t.Errorf("record %+v has DetectDuration %v, want 10ms", c, c.DetectDuration)
+		}
+	}
+}
@@ -1,255 +1,386 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"math"
 	"os"
-	"sort"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
+
+	"github.com/NimuraF/diplom-accumulate-service/api"
+	"github.com/NimuraF/diplom-accumulate-service/internal/feed"
+	"github.com/NimuraF/diplom-accumulate-service/internal/graph"
+	"github.com/NimuraF/diplom-accumulate-service/internal/metrics"
+	"github.com/NimuraF/diplom-accumulate-service/internal/store"
 )
 
-const INF = math.MaxFloat64
+// RateRecord для парсинга входных данных. Levels, если указан, задаёт полную
+// глубину стакана (топ-N уровней от лучшей цены к худшей); Rate остаётся
+// как цена верхнего уровня для источников, которые глубину не отдают.
+type RateRecord struct {
+	From     string             `json:"from"`
+	To       string             `json:"to"`
+	Rate     float64            `json:"rate"`
+	Fee      float64            `json:"fee"`
+	Exchange string             `json:"exchange"`
+	Levels   []graph.PriceLevel `json:"levels,omitempty"`
+}
 
-// Edge описывает одно направление с весом и биржей.
-type Edge struct {
-	To       string  `json:"to"`
-	Weight   float64 // -log(rate)
-	Exchange string
+// loadRecords читает JSON-массив RateRecord и добавляет валидные записи в g.
+func loadRecords(g *graph.Graph, records []RateRecord) {
+	for _, r := range records {
+		if len(r.Levels) > 0 {
+			g.AddEdgeDepth(r.From, r.To, r.Levels, r.Fee, r.Exchange)
+			continue
+		}
+		if r.Rate <= 0 {
+			continue
+		}
+		g.AddEdge(r.From, r.To, r.Rate, r.Fee, r.Exchange)
+	}
 }
 
-// Graph хранит вершины (currencies), ребра и для каждого from->to список возможных Edge.
-type Graph struct {
-	vertices []string
-	edges    map[string][]Edge
+// printRanked печатает топ-10 циклов по убыванию фактически исполнимого
+// профита (RankByExecutedProfit), вместе с исполняемыми ногами цикла.
+func printRanked(ranked []graph.RankedCycle) {
+	limit := 10
+	if len(ranked) < limit {
+		limit = len(ranked)
+	}
+	for i := 0; i < limit; i++ {
+		c := ranked[i]
+		fmt.Printf("#%d: simulated_profit=%.4f%%, filled=%.2f, %s cycle (starts and ends with %s): ",
+			i+1, c.SimulatedProfit*100, c.Filled, c.CycleType, c.Path[0])
+		for idx, v := range c.Path {
+			if idx > 0 {
+				fmt.Print(" -> ")
+			}
+			fmt.Print(v)
+		}
+		fmt.Println(" ->", c.Path[0])
+		for _, leg := range c.Legs {
+			fmt.Printf("    %-10s %-12s price=%.8f size=%.4f\n", leg.Exchange, leg.Side, leg.Price, leg.Size)
+		}
+	}
 }
 
-func NewGraph() *Graph {
-	return &Graph{
-		vertices: []string{},
-		edges:    make(map[string][]Edge),
+// scanAndPrint ищет циклы согласно opts, ранжирует их по реально исполнимому
+// профиту на notional единиц валюты start и печатает результат. Заодно
+// обновляет метрики Prometheus и, если st не nil, сохраняет найденные циклы
+// в историю.
+func scanAndPrint(g *graph.Graph, opts graph.CycleOptions, notional float64, st *store.Store) {
+	scanStart := time.Now()
+	cycles := g.FindCycles(opts)
+	ranked := g.RankByExecutedProfit(cycles, notional)
+	duration := time.Since(scanStart)
+
+	metrics.ObserveScan(cycles, duration)
+	vertices, edges := g.Dump()
+	metrics.ObserveGraphSnapshot(vertices, edges)
+	if st != nil {
+		st.RecordRanked(context.Background(), ranked, notional, duration)
 	}
+
+	printRanked(ranked)
 }
 
-// AddEdge регистрирует новую вершину и добавляет ребро.
-func (g *Graph) AddEdge(from, to string, rate float64, exchange string) {
-	if _, ok := g.edges[from]; !ok {
-		g.vertices = append(g.vertices, from)
+// processFile открывает и анализирует файл с курсами.
+func processFile(filename, mode, quote string, notional float64, st *store.Store) {
+	f, err := os.Open(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "open:", err)
+		return
 	}
-	if _, ok := g.edges[to]; !ok {
-		g.vertices = append(g.vertices, to)
+	defer f.Close()
+
+	var records []RateRecord
+	if err := json.NewDecoder(f).Decode(&records); err != nil {
+		fmt.Fprintln(os.Stderr, "decode:", err)
+		return
 	}
-	g.edges[from] = append(g.edges[from], Edge{
-		To:       to,
-		Weight:   -math.Log(rate),
-		Exchange: exchange,
-	})
-}
 
-// cycleInfo хранит один найденный цикл и его характеристики.
-type cycleInfo struct {
-	Path      []string
-	CycleType string
-	Profit    float64
+	g := graph.NewGraph()
+	loadRecords(g, records)
+
+	fmt.Println("==== Анализ файла", filename, "в", time.Now(), "====")
+	scanAndPrint(g, cycleOptions(mode, quote), notional, st)
 }
 
-// detectArbitrage запускает параллельно поиск циклов для каждой валюты.
-func (g *Graph) detectArbitrage() {
-	var wg sync.WaitGroup
-	var cycles []cycleInfo
-	var cyclesMtx sync.Mutex
-	uniqueCycles := make(map[string]bool)
+// cycleOptions строит graph.CycleOptions из общих CLI-флагов. Пустой quote
+// означает поиск от всех валют; непустой ограничивает старт одной валютой,
+// в единицах которой задан --notional.
+func cycleOptions(mode, quote string) graph.CycleOptions {
+	opts := graph.CycleOptions{Mode: mode}
+	if quote != "" {
+		opts.Starts = []string{quote}
+	}
+	return opts
+}
 
-	// Ограничиваем длину цикла числом переходов (ребер).
-	maxDepth := 5
+// defaultBinancePairs — пары, которые слушает BinanceSource при работе из CLI.
+// Пока без отдельного конфиг-формата: список пар фиксирован, как и раньше
+// был фиксирован интервал опроса файла.
+func defaultBinancePairs() map[string]feed.Pair {
+	return map[string]feed.Pair{
+		"btcusdt": {Base: "BTC", Quote: "USDT"},
+		"ethusdt": {Base: "ETH", Quote: "USDT"},
+		"ethbtc":  {Base: "ETH", Quote: "BTC"},
+	}
+}
 
-	// Для каждой валюты запускаем поиск циклов.
-	for _, start := range g.vertices {
-		wg.Add(1)
-		go func(s string) {
-			defer wg.Done()
-			visited := make(map[string]bool)
-			visited[s] = true
-			g.dfs(s, s, []string{s}, []string{}, 0, 0.0, visited, maxDepth, &cycles, &cyclesMtx, uniqueCycles)
-		}(start)
+// defaultKrakenPairs — аналог defaultBinancePairs для KrakenSource.
+func defaultKrakenPairs() map[string]feed.Pair {
+	return map[string]feed.Pair{
+		"XBT/USD": {Base: "BTC", Quote: "USD"},
+		"ETH/USD": {Base: "ETH", Quote: "USD"},
+		"ETH/XBT": {Base: "ETH", Quote: "BTC"},
 	}
-	wg.Wait()
+}
 
-	// Сортировка циклов по убыванию % прибыли.
-	sort.Slice(cycles, func(i, j int) bool {
-		return cycles[i].Profit > cycles[j].Profit
-	})
+// exchangeTakerFees — комиссия тейкера (доля) по имени источника (feed.RateSource.Name),
+// которую runFeed применяет к каждой котировке перед тем, как положить её в
+// граф: иначе профит циклов, обнаруженных по потоковым данным, был бы
+// посчитан без учёта комиссии, в отличие от файлового режима (loadRecords),
+// где её явно указывает сам файл с курсами. Значения — публичные ставки
+// тейкера по умолчанию (без скидок за объём/токен биржи).
+var exchangeTakerFees = map[string]float64{
+	"binance": 0.001,
+	"kraken":  0.0026,
+}
 
-	limit := 10
-	if len(cycles) < limit {
-		limit = len(cycles)
+// newFeedSource строит RateSource по одной записи флага --source. spec — это
+// либо голое имя биржи ("binance", "kraken"), либо ws(s):// URL, содержащий
+// имя биржи (тогда он используется как BaseURL вместо публичного эндпоинта,
+// что удобно для подключения к тестовым стендам).
+func newFeedSource(spec string) (feed.RateSource, error) {
+	switch {
+	case strings.Contains(spec, "binance"):
+		s := feed.NewBinanceSource(defaultBinancePairs())
+		if spec != "binance" {
+			s.BaseURL = spec
+		}
+		return s, nil
+	case strings.Contains(spec, "kraken"):
+		s := feed.NewKrakenSource(defaultKrakenPairs())
+		if spec != "kraken" {
+			s.BaseURL = spec
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown feed source %q (expected a binance or kraken ws:// URL)", spec)
 	}
+}
 
-	for i := 0; i < limit; i++ {
-		c := cycles[i]
-		// Явно выводим исходную валюту как начало и конец цикла.
-		fmt.Printf("#%d: profit=%.4f%%, %s cycle (starts and ends with %s): ", i+1, c.Profit*100, c.CycleType, c.Path[0])
-		for idx, v := range c.Path {
-			if idx > 0 {
-				fmt.Print(" -> ")
-			}
-			fmt.Print(v)
+// runFeed подключает перечисленные источники к g и держит его в актуальном
+// состоянии до отмены ctx. Возвращает, когда все источники исчерпали
+// переподключения и закрыли свои каналы. onTouched вызывается с набором
+// валют, затронутых очередной пачкой котировок — его использует CLI-режим
+// печати, чтобы пересчитывать арбитраж только по ним; в serve-режиме он не
+// нужен, т.к. запросы приходят через GET /cycles по требованию.
+func runFeed(ctx context.Context, g *graph.Graph, specs []string, onTouched func(starts []string)) {
+	merged := make(chan feed.RateRecord)
+	var wg sync.WaitGroup
+
+	for _, spec := range specs {
+		src, err := newFeedSource(spec)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "feed:", err)
+			continue
 		}
-		fmt.Printf(" -> %s\n", c.Path[0])
+		ch, err := src.Stream(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "feed:", err)
+			continue
+		}
+		wg.Add(1)
+		go func(ch <-chan feed.RateRecord) {
+			defer wg.Done()
+			for rec := range ch {
+				merged <- rec
+			}
+		}(ch)
 	}
-}
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+
+	const scanDebounce = 500 * time.Millisecond
+	const staleAfter = 2 * time.Minute
 
-// dfs ищет циклы с ограничением по глубине. Если цикл замыкается (current == start)
-// при любой глубине, не превышающей maxDepth, он регистрируется.
-func (g *Graph) dfs(
-	start, current string,
-	path, exchanges []string,
-	depth int,
-	accWeight float64,
-	visited map[string]bool,
-	maxDepth int,
-	cycles *[]cycleInfo,
-	cyclesMtx *sync.Mutex,
-	uniqueCycles map[string]bool,
-) {
-	// Если мы замкнули цикл (и это не тривиальный путь), регистрируем его.
-	if depth > 0 && current == start {
-		if accWeight < 0 { // прибыльный цикл
-			norm := normalizeCycle(path)
-			cyclesMtx.Lock()
-			if uniqueCycles[norm] {
-				cyclesMtx.Unlock()
+	touched := make(map[string]bool)
+	scanTimer := time.NewTimer(scanDebounce)
+	scanTimer.Stop()
+	expireTicker := time.NewTicker(staleAfter / 2)
+	defer expireTicker.Stop()
+
+	for {
+		select {
+		case rec, ok := <-merged:
+			if !ok {
 				return
 			}
-			uniqueCycles[norm] = true
-			cyclesMtx.Unlock()
-
-			// Определяем тип цикла по биржам.
-			exSet := make(map[string]struct{})
-			for _, ex := range exchanges {
-				exSet[ex] = struct{}{}
+			fee := exchangeTakerFees[rec.Exchange]
+			if len(rec.Levels) > 0 {
+				g.UpdateEdgeDepth(rec.From, rec.To, rec.Levels, fee, rec.Exchange, rec.Ts)
+			} else {
+				g.UpdateEdge(rec.From, rec.To, rec.Rate, fee, rec.Exchange, rec.Ts)
 			}
-			ctype := "intra-exchange"
-			if len(exSet) > 1 {
-				ctype = "inter-exchange"
+			if onTouched != nil {
+				touched[rec.From] = true
+				touched[rec.To] = true
+				scanTimer.Reset(scanDebounce)
 			}
-			// Вычисляем profit как: product - 1.
-			profit := math.Exp(-accWeight) - 1
-
-			cyclesMtx.Lock()
-			*cycles = append(*cycles, cycleInfo{
-				Path:      append([]string(nil), path...),
-				CycleType: ctype,
-				Profit:    profit,
-			})
-			cyclesMtx.Unlock()
-		}
-		// Замыкание цикла – не продолжаем расширять эту ветку.
-		return
-	}
-	if depth >= maxDepth {
-		return
-	}
-	// Продолжаем обход по всем ребрам из текущей валюты.
-	for _, e := range g.edges[current] {
-		next := e.To
-		// Разрешаем переход к исходной валюте даже если она уже посещена.
-		if next != start && visited[next] {
-			continue
-		}
-		newPath := append(path, next)
-		newExchanges := append(exchanges, e.Exchange)
-		if next != start {
-			visited[next] = true
-			g.dfs(start, next, newPath, newExchanges, depth+1, accWeight+e.Weight, visited, maxDepth, cycles, cyclesMtx, uniqueCycles)
-			visited[next] = false
-		} else {
-			g.dfs(start, next, newPath, newExchanges, depth+1, accWeight+e.Weight, visited, maxDepth, cycles, cyclesMtx, uniqueCycles)
+		case <-scanTimer.C:
+			if len(touched) == 0 {
+				continue
+			}
+			starts := make([]string, 0, len(touched))
+			for v := range touched {
+				starts = append(starts, v)
+			}
+			touched = make(map[string]bool)
+			onTouched(starts)
+		case <-expireTicker.C:
+			g.ExpireOlderThan(staleAfter)
+		case <-ctx.Done():
+			return
 		}
 	}
 }
 
-// normalizeCycle возвращает каноническое представление цикла без повторяющейся исходной валюты.
-func normalizeCycle(cycle []string) string {
-	n := len(cycle)
-	if n == 0 {
-		return ""
-	}
-	// Исключаем повторную исходную валюту (последний элемент), т.к. он совпадает с первым.
-	cycleNoDup := cycle[:n-1]
-	best := make([]string, len(cycleNoDup))
-	copy(best, cycleNoDup)
-	for i := 1; i < len(cycleNoDup); i++ {
-		rotated := append(append([]string(nil), cycleNoDup[i:]...), cycleNoDup[:i]...)
-		if lexLess(rotated, best) {
-			best = rotated
+// runFeedMode — CLI-режим: подключается к источникам и печатает в stdout
+// циклы, найденные среди валют, затронутых свежими котировками, вместо
+// периодического перечитывания файла раз в 5 секунд.
+func runFeedMode(specs []string, mode, quote string, notional float64, st *store.Store) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	g := graph.NewGraph()
+	runFeed(ctx, g, specs, func(starts []string) {
+		opts := cycleOptions(mode, quote)
+		if quote == "" {
+			// quote не задан: пересчитываем только затронутые валюты, как и раньше.
+			opts.Starts = starts
 		}
-	}
-	return strings.Join(best, "->")
+		scanAndPrint(g, opts, notional, st)
+	})
 }
 
-// lexLess обеспечивает лексикографическое сравнение двух срезов строк.
-func lexLess(a, b []string) bool {
-	n := len(a)
-	for i := 0; i < n; i++ {
-		if a[i] < b[i] {
-			return true
-		} else if a[i] > b[i] {
-			return false
+// runServeMode запускает HTTP API поверх графа, при необходимости предварительно
+// загружая его из файла и/или подпитывая биржевыми фидами, и работает до
+// получения SIGINT/SIGTERM, после чего грациозно останавливается.
+func runServeMode(addr, file string, sources []string, st *store.Store) {
+	g := graph.NewGraph()
+
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "open:", err)
+			os.Exit(1)
+		}
+		var records []RateRecord
+		err = json.NewDecoder(f).Decode(&records)
+		f.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "decode:", err)
+			os.Exit(1)
 		}
+		loadRecords(g, records)
 	}
-	return false
-}
 
-// RateRecord для парсинга входных данных.
-type RateRecord struct {
-	From     string  `json:"from"`
-	To       string  `json:"to"`
-	Rate     float64 `json:"rate"`
-	Exchange string  `json:"exchange"`
-}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-// processFile открывает и анализирует файл с курсами.
-func processFile(filename string) {
-	f, err := os.Open(filename)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "open:", err)
-		return
+	if len(sources) > 0 {
+		go runFeed(ctx, g, sources, nil)
 	}
-	defer f.Close()
 
-	var records []RateRecord
-	if err := json.NewDecoder(f).Decode(&records); err != nil {
-		fmt.Fprintln(os.Stderr, "decode:", err)
-		return
-	}
+	srv := api.NewServer(g, addr, st)
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.ListenAndServe() }()
 
-	g := NewGraph()
-	for _, r := range records {
-		if r.Rate <= 0 {
-			continue
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-serveErr:
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "serve:", err)
+			os.Exit(1)
+		}
+	case <-stop:
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer shutdownCancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			fmt.Fprintln(os.Stderr, "shutdown:", err)
 		}
-		g.AddEdge(r.From, r.To, r.Rate, r.Exchange)
 	}
-
-	fmt.Println("==== Анализ файла", filename, "в", time.Now(), "====")
-	g.detectArbitrage()
 }
 
 func main() {
 	var file string
+	var mode string
+	var source string
+	var addr string
+	var quote string
+	var notional float64
+	var dbPath string
+	var metricsAddr string
 	flag.StringVar(&file, "f", "", "JSON file with rate records (default stdin)")
+	flag.StringVar(&mode, "mode", "bf", "cycle search mode: bf (Bellman-Ford, scales to large graphs) or dfs (Johnson's algorithm, enumerates all elementary cycles)")
+	flag.StringVar(&source, "source", "file", "data source: 'file' to poll -f/stdin, or a comma-separated list of exchange feeds (e.g. binance,kraken or ws://host:port)")
+	flag.StringVar(&addr, "addr", "", "if set, run an HTTP API on this address (e.g. :8080) instead of printing to stdout")
+	flag.StringVar(&quote, "quote", "", "if set, only look for cycles starting/ending in this currency (notional is denominated in it)")
+	flag.Float64Var(&notional, "notional", 1000, "notional size to simulate each cycle at, for slippage-aware ranking")
+	flag.StringVar(&dbPath, "db", "", "if set, path to a SQLite file where every detected cycle is recorded for later analysis")
+	flag.StringVar(&metricsAddr, "metrics-addr", "", "if set, serve Prometheus metrics on this address (e.g. :9090)")
 	flag.Parse()
 
+	var st *store.Store
+	if dbPath != "" {
+		var err error
+		st, err = store.Open(dbPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "store:", err)
+			os.Exit(1)
+		}
+		defer st.Close()
+	}
+
+	if metricsAddr != "" {
+		go func() {
+			if err := metrics.Serve(metricsAddr); err != nil {
+				fmt.Fprintln(os.Stderr, "metrics:", err)
+			}
+		}()
+	}
+
+	if addr != "" {
+		var sources []string
+		if source != "file" {
+			sources = strings.Split(source, ",")
+		}
+		runServeMode(addr, file, sources, st)
+		return
+	}
+
+	if source != "file" {
+		runFeedMode(strings.Split(source, ","), mode, quote, notional, st)
+		return
+	}
+
 	if file != "" {
 		// При указанном файле запускаем анализ каждые 5 секунд.
 		ticker := time.NewTicker(5 * time.Second)
 		defer ticker.Stop()
 		for {
-			processFile(file)
+			processFile(file, mode, quote, notional, st)
 			<-ticker.C
 		}
 	} else {
@@ -260,13 +391,8 @@ func main() {
 			os.Exit(1)
 		}
 
-		g := NewGraph()
-		for _, r := range records {
-			if r.Rate <= 0 {
-				continue
-			}
-			g.AddEdge(r.From, r.To, r.Rate, r.Exchange)
-		}
-		g.detectArbitrage()
+		g := graph.NewGraph()
+		loadRecords(g, records)
+		scanAndPrint(g, cycleOptions(mode, quote), notional, st)
 	}
 }